@@ -0,0 +1,238 @@
+// Package bmp implements a BMP image decoder for the uncompressed variants: 1, 4 and 8-bit
+// paletted images and 16, 24 and 32-bit true color images, including the bit field masks
+// of the V4 and V5 headers. Run-length encoded images are not supported.
+package bmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+)
+
+// compression methods
+const (
+	biRGB            = 0
+	biBitFields      = 3
+	biAlphaBitFields = 6
+)
+
+var (
+	// ErrFormat is returned when the input is not a valid BMP image
+	ErrFormat = errors.New("bmp: invalid format")
+	// ErrUnsupported is returned for valid BMP images this package cannot decode, such as RLE ones
+	ErrUnsupported = errors.New("bmp: unsupported format")
+)
+
+func init() {
+	image.RegisterFormat("bmp", "BM????\x00\x00\x00\x00", Decode, DecodeConfig)
+}
+
+// header is the description of the raster
+type header struct {
+	width, height int
+	topDown       bool
+	bpp           int
+	masks         [4]uint32 // red, green, blue and alpha bit fields of true color images
+	palette       color.Palette
+	skip          int // bytes between the headers and the raster
+}
+
+// DecodeConfig return the color model and dimensions of a BMP image without decoding it
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	if h.palette != nil {
+		return image.Config{ColorModel: h.palette, Width: h.width, Height: h.height}, nil
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: h.width, Height: h.height}, nil
+}
+
+// Decode read a BMP image as an *image.Paletted for paletted images and as an *image.NRGBA otherwise
+func Decode(r io.Reader) (image.Image, error) {
+	var x, y, row int
+
+	h, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.CopyN(io.Discard, r, int64(h.skip)); err != nil {
+		return nil, unexpected(err)
+	}
+
+	rect := image.Rect(0, 0, h.width, h.height)
+	line := make([]byte, (h.width*h.bpp+31)/32*4)
+	var paletted *image.Paletted
+	var nrgba *image.NRGBA
+	if h.palette != nil {
+		paletted = image.NewPaletted(rect, h.palette)
+	} else {
+		nrgba = image.NewNRGBA(rect)
+	}
+
+	for row = 0; row < h.height; row++ {
+		if _, err = io.ReadFull(r, line); err != nil {
+			return nil, unexpected(err)
+		}
+		y = h.height - 1 - row
+		if h.topDown {
+			y = row
+		}
+
+		if paletted != nil {
+			pix := paletted.Pix[y*paletted.Stride:]
+			perByte := 8 / h.bpp
+			for x = 0; x < h.width; x++ {
+				shift := 8 - h.bpp*(x%perByte+1)
+				pix[x] = line[x/perByte] >> shift & (1<<h.bpp - 1)
+				if int(pix[x]) >= len(h.palette) {
+					return nil, fmt.Errorf("%w: color index %d out of the palette", ErrFormat, pix[x])
+				}
+			}
+			continue
+		}
+
+		pix := nrgba.Pix[y*nrgba.Stride:]
+		for x = 0; x < h.width; x++ {
+			var v uint32
+			switch h.bpp {
+			case 16:
+				v = uint32(binary.LittleEndian.Uint16(line[x*2:]))
+			case 24:
+				v = uint32(line[x*3]) | uint32(line[x*3+1])<<8 | uint32(line[x*3+2])<<16
+			case 32:
+				v = binary.LittleEndian.Uint32(line[x*4:])
+			}
+			pix[x*4], pix[x*4+1], pix[x*4+2] = field(v, h.masks[0]), field(v, h.masks[1]), field(v, h.masks[2])
+			pix[x*4+3] = 255
+			if h.masks[3] != 0 {
+				pix[x*4+3] = field(v, h.masks[3])
+			}
+		}
+	}
+
+	if paletted != nil {
+		return paletted, nil
+	}
+	return nrgba, nil
+}
+
+// field extract the bit field of the mask from v, scaled to 8 bits
+func field(v, mask uint32) uint8 {
+	if mask == 0 {
+		return 0
+	}
+	max := uint32(1)<<bits.OnesCount32(mask) - 1
+	return uint8(((v&mask)>>bits.TrailingZeros32(mask)*255 + max/2) / max)
+}
+
+func readHeader(r io.Reader) (header, error) {
+	var h header
+	var file [18]byte
+	var compression uint32
+	var colors int
+
+	// the file header and the size of the info header
+	if _, err := io.ReadFull(r, file[:]); err != nil {
+		return h, err
+	}
+	if string(file[:2]) != "BM" {
+		return h, ErrFormat
+	}
+	offset := int(binary.LittleEndian.Uint32(file[10:]))
+	size := int(binary.LittleEndian.Uint32(file[14:]))
+	if size != 12 && size < 40 || size > 1024 {
+		return h, fmt.Errorf("%w: info header of %d bytes", ErrUnsupported, size)
+	}
+	info := make([]byte, size)
+	if _, err := io.ReadFull(r, info[4:]); err != nil {
+		return h, unexpected(err)
+	}
+	read := len(file) + len(info) - 4
+
+	paletteEntry := 4
+	if size == 12 {
+		// OS/2 core header
+		h.width, h.height = int(binary.LittleEndian.Uint16(info[4:])), int(binary.LittleEndian.Uint16(info[6:]))
+		h.bpp, paletteEntry = int(binary.LittleEndian.Uint16(info[10:])), 3
+	} else {
+		h.width, h.height = int(int32(binary.LittleEndian.Uint32(info[4:]))), int(int32(binary.LittleEndian.Uint32(info[8:])))
+		h.bpp = int(binary.LittleEndian.Uint16(info[14:]))
+		compression = binary.LittleEndian.Uint32(info[16:])
+		colors = int(binary.LittleEndian.Uint32(info[32:]))
+	}
+	if h.height < 0 {
+		h.height, h.topDown = -h.height, true
+	}
+	if h.width < 1 || h.height < 1 || h.width > 1<<30 || h.height > 1<<30 || int64(h.width)*int64(h.height) > 1<<31/4 {
+		return h, fmt.Errorf("%w: unsupported size %dx%d", ErrFormat, h.width, h.height)
+	}
+
+	switch {
+	case compression == biRGB:
+		switch h.bpp {
+		case 16:
+			h.masks = [4]uint32{0x7c00, 0x03e0, 0x001f, 0}
+		case 24, 32:
+			h.masks = [4]uint32{0xff0000, 0x00ff00, 0x0000ff, 0}
+		}
+	case (compression == biBitFields || compression == biAlphaBitFields) && (h.bpp == 16 || h.bpp == 32):
+		n := 3
+		if compression == biAlphaBitFields {
+			n = 4
+		}
+		masks := info[40:]
+		if size == 40 {
+			// the masks follow a plain info header
+			masks = make([]byte, n*4)
+			if _, err := io.ReadFull(r, masks); err != nil {
+				return h, unexpected(err)
+			}
+			read += len(masks)
+		} else if size >= 56 {
+			n = 4
+		}
+		for i := 0; i < n && len(masks) >= 4*(i+1); i++ {
+			h.masks[i] = binary.LittleEndian.Uint32(masks[4*i:])
+		}
+	default:
+		return h, fmt.Errorf("%w: compression %d with %d bits per pixel", ErrUnsupported, compression, h.bpp)
+	}
+
+	switch h.bpp {
+	case 1, 4, 8:
+		if colors == 0 || colors > 1<<h.bpp {
+			colors = 1 << h.bpp
+		}
+		entries := make([]byte, colors*paletteEntry)
+		if _, err := io.ReadFull(r, entries); err != nil {
+			return h, unexpected(err)
+		}
+		read += len(entries)
+		h.palette = make(color.Palette, colors)
+		for i := range h.palette {
+			e := entries[i*paletteEntry:]
+			h.palette[i] = color.RGBA{R: e[2], G: e[1], B: e[0], A: 255}
+		}
+	case 16, 24, 32:
+	default:
+		return h, fmt.Errorf("%w: %d bits per pixel", ErrUnsupported, h.bpp)
+	}
+
+	if h.skip = offset - read; h.skip < 0 {
+		return h, fmt.Errorf("%w: pixel data at offset %d overlaps the headers", ErrFormat, offset)
+	}
+	return h, nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,71 @@
+package bmp
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	f, err := os.Open("../../example/fixture.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPix := want.(*image.NRGBA).Pix
+
+	decode := func(name string) image.Image {
+		data, err := os.ReadFile("../../example/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, format, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if format != "bmp" || img.Bounds() != want.Bounds() {
+			t.Fatalf("%s: unexpected %s image of bounds %v", name, format, img.Bounds())
+		}
+		return img
+	}
+
+	// 24-bit bottom-up rows padded to 4 bytes
+	pix := decode("fixture.bmp").(*image.NRGBA).Pix
+	for i := range pix {
+		if expected := wantPix[i]; i%4 != 3 && pix[i] != expected || i%4 == 3 && pix[i] != 255 {
+			t.Fatalf("fixture.bmp: sample %d: expected %d, got %d", i, expected, pix[i])
+		}
+	}
+
+	// 32-bit top-down with an alpha bit field
+	if pix = decode("fixture-alpha.bmp").(*image.NRGBA).Pix; !bytes.Equal(pix, wantPix) {
+		t.Error("fixture-alpha.bmp: decoded pixels differ from the PNG fixture")
+	}
+
+	// 8-bit paletted gray levels
+	paletted := decode("fixture-gray.bmp").(*image.Paletted)
+	for i := range paletted.Pix {
+		gray := uint8((int(wantPix[4*i]) + int(wantPix[4*i+1]) + int(wantPix[4*i+2])) / 3)
+		if paletted.Pix[i] != gray || paletted.Palette[gray] != (color.RGBA{R: gray, G: gray, B: gray, A: 255}) {
+			t.Fatalf("fixture-gray.bmp: pixel %d: expected %d, got %d", i, gray, paletted.Pix[i])
+		}
+	}
+
+	// run-length encoded images are not supported
+	data, err := os.ReadFile("../../example/fixture-gray.bmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[30] = 1
+	if _, err = Decode(bytes.NewReader(data)); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+// Package farbfeld implements a farbfeld image decoder.
+//
+// The format is a magic string, the width and height as 32-bit big-endian integers and then
+// the pixels as 16-bit big-endian RGBA values, not premultiplied by alpha.
+package farbfeld
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+const magic = "farbfeld"
+
+// ErrFormat is returned when the input is not a valid farbfeld image
+var ErrFormat = errors.New("farbfeld: invalid format")
+
+func init() {
+	image.RegisterFormat("farbfeld", magic, Decode, DecodeConfig)
+}
+
+// DecodeConfig return the color model and dimensions of a farbfeld image without decoding it
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	var header [16]byte
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return image.Config{}, err
+	}
+	if string(header[:8]) != magic {
+		return image.Config{}, ErrFormat
+	}
+	width, height := binary.BigEndian.Uint32(header[8:]), binary.BigEndian.Uint32(header[12:])
+	if width > 1<<30 || height > 1<<30 || uint64(width)*uint64(height) > 1<<31/8 {
+		return image.Config{}, errors.New("farbfeld: image too large")
+	}
+	return image.Config{ColorModel: color.NRGBA64Model, Width: int(width), Height: int(height)}, nil
+}
+
+// Decode read a farbfeld image as an *image.NRGBA64
+func Decode(r io.Reader) (image.Image, error) {
+	cfg, err := DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewNRGBA64(image.Rect(0, 0, cfg.Width, cfg.Height))
+	if _, err = io.ReadFull(r, img.Pix); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	// the pixels of an NRGBA64 image are big-endian as well
+	return img, nil
+}
@@ -0,0 +1,44 @@
+package farbfeld
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	f, err := os.Open("../../example/fixture.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile("../../example/fixture.ff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "farbfeld" || img.Bounds() != want.Bounds() {
+		t.Fatalf("unexpected %s image of bounds %v", format, img.Bounds())
+	}
+	pix, wantPix := img.(*image.NRGBA64).Pix, want.(*image.NRGBA).Pix
+	for i := range wantPix {
+		if pix[2*i] != wantPix[i] || pix[2*i+1] != wantPix[i] {
+			t.Fatalf("sample %d: expected %d, got %d %d", i, wantPix[i], pix[2*i], pix[2*i+1])
+		}
+	}
+
+	if _, err = Decode(bytes.NewReader(data[:len(data)-1])); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF for truncated data, got %v", err)
+	}
+}
@@ -0,0 +1,395 @@
+// Package netpbm implements decoders for the Netpbm formats: PBM, PGM and PPM in their plain
+// and raw variants, and PAM.
+//
+// Samples are scaled from the maximum value declared by the header to the full range,
+// images with a maximum value over 255 are decoded to 16 bits per channel.
+package netpbm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrFormat is returned when the input is not a valid Netpbm image
+var ErrFormat = errors.New("netpbm: invalid format")
+
+func init() {
+	for _, f := range []struct{ name, magic string }{
+		{"pbm", "P1"}, {"pbm", "P4"},
+		{"pgm", "P2"}, {"pgm", "P5"},
+		{"ppm", "P3"}, {"ppm", "P6"},
+		{"pam", "P7"},
+	} {
+		image.RegisterFormat(f.name, f.magic, Decode, DecodeConfig)
+	}
+}
+
+// header is the description of the raster
+type header struct {
+	magic         string
+	width, height int
+	depth         int // number of channels
+	maxval        int
+	alpha         bool // the last channel is alpha
+}
+
+// DecodeConfig return the color model and dimensions of a Netpbm image without decoding it
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, err := readHeader(bufio.NewReader(r))
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: h.model(), Width: h.width, Height: h.height}, nil
+}
+
+// Decode read a Netpbm image as an *image.Gray or *image.Gray16 for black and white and grayscale images
+// and as an *image.NRGBA or *image.NRGBA64 otherwise
+func Decode(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	var next func() (int, error)
+	switch h.magic {
+	case "P1":
+		next = func() (int, error) { return readBit(br) }
+	case "P2", "P3":
+		next = func() (int, error) { return readInt(br) }
+	case "P4":
+		return decodeBitmap(br, h)
+	default:
+		next = rawReader(br, h.maxval)
+	}
+	return decodeSamples(next, h)
+}
+
+// decodeSamples read the samples one by one into the image, scaling them to the full range
+func decodeSamples(next func() (int, error), h header) (image.Image, error) {
+	var i, c, v, size int
+	var samples [4]int
+	var err error
+
+	// 16-bit images hold big-endian samples, the alpha of gray images is the second channel of NRGBA
+	size = 1
+	if h.maxval > 255 {
+		size = 2
+	}
+	full := 1<<(8*size) - 1
+
+	rect := image.Rect(0, 0, h.width, h.height)
+	var pix []uint8
+	var img image.Image
+	gray := h.depth-btoi(h.alpha) == 1
+	switch {
+	case gray && !h.alpha && size == 1:
+		m := image.NewGray(rect)
+		pix, img = m.Pix, m
+	case gray && !h.alpha:
+		m := image.NewGray16(rect)
+		pix, img = m.Pix, m
+	case size == 1:
+		m := image.NewNRGBA(rect)
+		pix, img = m.Pix, m
+	default:
+		m := image.NewNRGBA64(rect)
+		pix, img = m.Pix, m
+	}
+
+	for i = 0; i < len(pix); {
+		for c = 0; c < h.depth; c++ {
+			if v, err = next(); err != nil {
+				return nil, err
+			}
+			if v > h.maxval {
+				return nil, fmt.Errorf("%w: sample %d over the maximum value %d", ErrFormat, v, h.maxval)
+			}
+			samples[c] = (v*full + h.maxval/2) / h.maxval
+		}
+
+		switch {
+		case gray && !h.alpha:
+			i = put(pix, i, size, samples[0])
+		case gray:
+			i = put(pix, i, size, samples[0])
+			i = put(pix, i, size, samples[0])
+			i = put(pix, i, size, samples[0])
+			i = put(pix, i, size, samples[1])
+		default:
+			i = put(pix, i, size, samples[0])
+			i = put(pix, i, size, samples[1])
+			i = put(pix, i, size, samples[2])
+			if h.alpha {
+				i = put(pix, i, size, samples[3])
+			} else {
+				i = put(pix, i, size, full)
+			}
+		}
+	}
+	return img, nil
+}
+
+// decodeBitmap read a raw PBM, whose rows are packed 8 pixels per byte with 1 standing for black
+func decodeBitmap(br *bufio.Reader, h header) (image.Image, error) {
+	var x, y int
+
+	img := image.NewGray(image.Rect(0, 0, h.width, h.height))
+	row := make([]byte, (h.width+7)/8)
+	for y = 0; y < h.height; y++ {
+		if _, err := io.ReadFull(br, row); err != nil {
+			return nil, unexpected(err)
+		}
+		for x = 0; x < h.width; x++ {
+			if row[x/8]&(0x80>>(x%8)) == 0 {
+				img.Pix[y*img.Stride+x] = 255
+			}
+		}
+	}
+	return img, nil
+}
+
+func put(pix []uint8, i, size, v int) int {
+	if size == 2 {
+		pix[i], pix[i+1] = uint8(v>>8), uint8(v)
+		return i + 2
+	}
+	pix[i] = uint8(v)
+	return i + 1
+}
+
+// rawReader read binary samples of one byte, or two big-endian bytes when maxval is over 255
+func rawReader(br *bufio.Reader, maxval int) func() (int, error) {
+	return func() (int, error) {
+		hi, err := br.ReadByte()
+		if err != nil {
+			return 0, unexpected(err)
+		}
+		if maxval <= 255 {
+			return int(hi), nil
+		}
+		lo, err := br.ReadByte()
+		if err != nil {
+			return 0, unexpected(err)
+		}
+		return int(hi)<<8 | int(lo), nil
+	}
+}
+
+// readBit read a sample of a plain PBM, where 1 stands for black and digits need not be separated
+func readBit(br *bufio.Reader) (int, error) {
+	if err := skipSpace(br); err != nil {
+		return 0, unexpected(err)
+	}
+	b, err := br.ReadByte()
+	if err != nil {
+		return 0, unexpected(err)
+	}
+	switch b {
+	case '0':
+		return 1, nil
+	case '1':
+		return 0, nil
+	}
+	return 0, fmt.Errorf("%w: unexpected %q in bitmap", ErrFormat, b)
+}
+
+func readHeader(br *bufio.Reader) (header, error) {
+	var h header
+	var magic [2]byte
+	var err error
+
+	if _, err = io.ReadFull(br, magic[:]); err != nil {
+		return h, err
+	}
+	h.magic = string(magic[:])
+
+	switch h.magic {
+	case "P1", "P4":
+		h.depth, h.maxval = 1, 1
+		if h.width, err = readInt(br); err == nil {
+			h.height, err = readInt(br)
+		}
+	case "P2", "P5", "P3", "P6":
+		h.depth = 1
+		if h.magic == "P3" || h.magic == "P6" {
+			h.depth = 3
+		}
+		if h.width, err = readInt(br); err == nil {
+			if h.height, err = readInt(br); err == nil {
+				h.maxval, err = readInt(br)
+			}
+		}
+	case "P7":
+		err = readPAMHeader(br, &h)
+	default:
+		return h, ErrFormat
+	}
+	if err != nil {
+		return h, err
+	}
+
+	// a single whitespace separates the header of raw formats from the raster
+	if h.magic != "P1" && h.magic != "P2" && h.magic != "P3" && h.magic != "P7" {
+		if b, err := br.ReadByte(); err != nil || !isSpace(b) {
+			return h, fmt.Errorf("%w: missing whitespace after the header", ErrFormat)
+		}
+	}
+
+	if h.width < 1 || h.height < 1 || h.width > 1<<30 || h.height > 1<<30 || int64(h.width)*int64(h.height) > 1<<31/8 {
+		return h, fmt.Errorf("%w: unsupported size %dx%d", ErrFormat, h.width, h.height)
+	}
+	if h.maxval < 1 || h.maxval > 65535 {
+		return h, fmt.Errorf("%w: maximum value %d out of range", ErrFormat, h.maxval)
+	}
+	if h.depth < 1 || h.depth > 4 {
+		return h, fmt.Errorf("%w: unsupported depth %d", ErrFormat, h.depth)
+	}
+	return h, nil
+}
+
+// readPAMHeader read the PAM header lines up to ENDHDR
+func readPAMHeader(br *bufio.Reader, h *header) error {
+	var tupltype string
+
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return unexpected(err)
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] == "ENDHDR" {
+			break
+		}
+		if fields[0] == "TUPLTYPE" {
+			tupltype = strings.Join(fields[1:], " ")
+			continue
+		}
+		if len(fields) != 2 {
+			return fmt.Errorf("%w: malformed header line %q", ErrFormat, strings.TrimSpace(line))
+		}
+		v, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrFormat, err)
+		}
+		switch fields[0] {
+		case "WIDTH":
+			h.width = v
+		case "HEIGHT":
+			h.height = v
+		case "DEPTH":
+			h.depth = v
+		case "MAXVAL":
+			h.maxval = v
+		}
+	}
+
+	depth := h.depth
+	switch tupltype {
+	case "BLACKANDWHITE", "GRAYSCALE":
+		depth = 1
+	case "BLACKANDWHITE_ALPHA", "GRAYSCALE_ALPHA":
+		depth, h.alpha = 2, true
+	case "RGB":
+		depth = 3
+	case "RGB_ALPHA":
+		depth, h.alpha = 4, true
+	case "":
+		// guess from the depth
+		h.alpha = h.depth == 2 || h.depth == 4
+	default:
+		return fmt.Errorf("%w: unsupported tuple type %s", ErrFormat, tupltype)
+	}
+	if depth != h.depth {
+		return fmt.Errorf("%w: depth %d does not match tuple type %s", ErrFormat, h.depth, tupltype)
+	}
+	return nil
+}
+
+// readInt read a decimal number after skipping whitespace and comments
+func readInt(br *bufio.Reader) (int, error) {
+	var v int
+
+	if err := skipSpace(br); err != nil {
+		return 0, unexpected(err)
+	}
+	digits := 0
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF && digits > 0 {
+			return v, nil
+		}
+		if err != nil {
+			return 0, unexpected(err)
+		}
+		if b < '0' || b > '9' {
+			if digits == 0 || !isSpace(b) && b != '#' {
+				return 0, fmt.Errorf("%w: unexpected %q in number", ErrFormat, b)
+			}
+			return v, br.UnreadByte()
+		}
+		if v = v*10 + int(b-'0'); v > 1<<30 {
+			return 0, fmt.Errorf("%w: number too large", ErrFormat)
+		}
+		digits++
+	}
+}
+
+// skipSpace skip whitespace and comments, which run from # to the end of the line
+func skipSpace(br *bufio.Reader) error {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		if b == '#' {
+			if _, err = br.ReadString('\n'); err != nil {
+				return err
+			}
+			continue
+		}
+		if !isSpace(b) {
+			return br.UnreadByte()
+		}
+	}
+}
+
+func (h header) model() color.Model {
+	gray := h.depth-btoi(h.alpha) == 1
+	switch {
+	case gray && !h.alpha && h.maxval > 255:
+		return color.Gray16Model
+	case gray && !h.alpha:
+		return color.GrayModel
+	case h.maxval > 255:
+		return color.NRGBA64Model
+	}
+	return color.NRGBAModel
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\v' || b == '\f' || b == '\r'
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
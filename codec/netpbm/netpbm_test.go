@@ -0,0 +1,95 @@
+package netpbm
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDecodeFixtures(t *testing.T) {
+	f, err := os.Open("../../example/fixture.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPix := want.(*image.NRGBA).Pix
+
+	for _, name := range []string{"ppm", "pam"} {
+		data, err := os.ReadFile("../../example/fixture." + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, format, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != name || img.Bounds() != want.Bounds() {
+			t.Fatalf("unexpected %s image of bounds %v", format, img.Bounds())
+		}
+		pix := img.(*image.NRGBA).Pix
+		for i := range pix {
+			expected := wantPix[i]
+			if name == "ppm" && i%4 == 3 {
+				expected = 255
+			}
+			if pix[i] != expected {
+				t.Fatalf("%s: sample %d: expected %d, got %d", name, i, expected, pix[i])
+			}
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	for _, c := range []struct {
+		data   string
+		format string
+		pix    []uint8
+	}{
+		{"P1\n# plain bitmap\n3 2\n0 1 0\n110", "pbm", []uint8{255, 0, 255, 0, 0, 255}},
+		{"P4 3 2\n\x40\xc0", "pbm", []uint8{255, 0, 255, 0, 0, 255}},
+		{"P2\n2 1 15 # comment\n0 15\n", "pgm", []uint8{0, 255}},
+		{"P5 2 1 1023\n\x00\x00\x03\xff", "pgm", []uint8{0, 0, 255, 255}},
+		{"P3 1 1 3 1 2 3", "ppm", []uint8{85, 170, 255, 255}},
+		{"P7\nWIDTH 1\nHEIGHT 1\nDEPTH 2\nMAXVAL 255\nTUPLTYPE GRAYSCALE_ALPHA\nENDHDR\n\x10\x80", "pam", []uint8{16, 16, 16, 128}},
+		{"P7\nWIDTH 2\nHEIGHT 1\nDEPTH 1\nMAXVAL 1\nTUPLTYPE BLACKANDWHITE\nENDHDR\n\x00\x01", "pam", []uint8{0, 255}},
+	} {
+		img, format, err := image.Decode(strings.NewReader(c.data))
+		if err != nil {
+			t.Errorf("%q: %v", c.data, err)
+			continue
+		}
+		var pix []uint8
+		switch img := img.(type) {
+		case *image.Gray:
+			pix = img.Pix
+		case *image.Gray16:
+			pix = img.Pix
+		case *image.NRGBA:
+			pix = img.Pix
+		}
+		if format != c.format || !bytes.Equal(pix, c.pix) {
+			t.Errorf("%q: expected %s %v, got %s %T %v", c.data, c.format, c.pix, format, img, pix)
+		}
+	}
+
+	for _, data := range []string{
+		"P2 2 1 15 0 16",
+		"P6 1 1 255 \x00",
+		"P7\nWIDTH 1\nHEIGHT 1\nDEPTH 2\nMAXVAL 255\nTUPLTYPE RGB\nENDHDR\n\x00\x00",
+		"P5 0 1 255\n",
+	} {
+		if _, err := Decode(strings.NewReader(data)); err == nil {
+			t.Errorf("%q: expected an error", data)
+		} else if !errors.Is(err, ErrFormat) && !strings.Contains(err.Error(), "EOF") {
+			t.Errorf("%q: unexpected error %v", data, err)
+		}
+	}
+}
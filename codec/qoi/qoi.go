@@ -0,0 +1,111 @@
+// Package qoi implements a decoder for the Quite OK Image format, see https://qoiformat.org/qoi-specification.pdf
+package qoi
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+const magic = "qoif"
+
+const (
+	opIndex = 0x00 // 00xxxxxx
+	opDiff  = 0x40 // 01xxxxxx
+	opLuma  = 0x80 // 10xxxxxx
+	opRun   = 0xc0 // 11xxxxxx
+	opRGB   = 0xfe
+	opRGBA  = 0xff
+	opMask  = 0xc0
+)
+
+// ErrFormat is returned when the input is not a valid QOI image
+var ErrFormat = errors.New("qoi: invalid format")
+
+func init() {
+	image.RegisterFormat("qoi", magic, Decode, DecodeConfig)
+}
+
+// DecodeConfig return the color model and dimensions of a QOI image without decoding it
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	var header [14]byte
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return image.Config{}, err
+	}
+	if string(header[:4]) != magic || (header[12] != 3 && header[12] != 4) || header[13] > 1 {
+		return image.Config{}, ErrFormat
+	}
+	width, height := binary.BigEndian.Uint32(header[4:]), binary.BigEndian.Uint32(header[8:])
+	if width > 1<<30 || height > 1<<30 || uint64(width)*uint64(height) > 1<<31/4 {
+		return image.Config{}, errors.New("qoi: image too large")
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: int(width), Height: int(height)}, nil
+}
+
+// Decode read a QOI image as an *image.NRGBA, the colors are not premultiplied by alpha
+func Decode(r io.Reader) (image.Image, error) {
+	var index [64][4]uint8
+	var px [4]uint8
+	var run, i int
+	var b byte
+	var err error
+
+	cfg, err := DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(r)
+
+	img := image.NewNRGBA(image.Rect(0, 0, cfg.Width, cfg.Height))
+	px = [4]uint8{0, 0, 0, 255}
+	for i = 0; i < len(img.Pix); i += 4 {
+		if run > 0 {
+			run--
+		} else {
+			if b, err = br.ReadByte(); err != nil {
+				return nil, unexpected(err)
+			}
+			switch {
+			case b == opRGB:
+				if _, err = io.ReadFull(br, px[:3]); err != nil {
+					return nil, unexpected(err)
+				}
+			case b == opRGBA:
+				if _, err = io.ReadFull(br, px[:]); err != nil {
+					return nil, unexpected(err)
+				}
+			case b&opMask == opIndex:
+				px = index[b]
+			case b&opMask == opDiff:
+				px[0] += (b>>4)&3 - 2
+				px[1] += (b>>2)&3 - 2
+				px[2] += b&3 - 2
+			case b&opMask == opLuma:
+				var b2 byte
+				if b2, err = br.ReadByte(); err != nil {
+					return nil, unexpected(err)
+				}
+				dg := b&0x3f - 32
+				px[0] += dg - 8 + (b2>>4)&0x0f
+				px[1] += dg
+				px[2] += dg - 8 + b2&0x0f
+			case b&opMask == opRun:
+				run = int(b & 0x3f)
+			}
+			index[(int(px[0])*3+int(px[1])*5+int(px[2])*7+int(px[3])*11)%64] = px
+		}
+		copy(img.Pix[i:i+4], px[:])
+	}
+	return img, nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,47 @@
+package qoi
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	f, err := os.Open("../../example/fixture.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the fixture holds every kind of chunk
+	data, err := os.ReadFile("../../example/fixture.qoi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "qoi" || img.Bounds() != want.Bounds() {
+		t.Fatalf("unexpected %s image of bounds %v", format, img.Bounds())
+	}
+	if !bytes.Equal(img.(*image.NRGBA).Pix, want.(*image.NRGBA).Pix) {
+		t.Error("decoded pixels differ from the PNG fixture")
+	}
+
+	if _, err = Decode(bytes.NewReader(data[:len(data)/2])); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF for truncated data, got %v", err)
+	}
+	bad := append([]byte{}, data...)
+	bad[12] = 5 // channels
+	if _, err = DecodeConfig(bytes.NewReader(bad)); err != ErrFormat {
+		t.Errorf("expected ErrFormat, got %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package colorspace
+
+import (
+	"fmt"
+	"math"
+)
+
+// Space identifies the color space in which a quantizer clusters the pixels
+type Space int
+
+const (
+	RGB       Space = iota // gamma encoded sRGB, the space the pixels are sampled in
+	LinearRGB              // sRGB decoded to linear light, colors are averaged the way light mixes
+	Lab                    // CIELAB under the D65 white point, distances approximate the perceived difference
+	OKLab                  // Björn Ottosson's OKLab, more uniform than CIELAB for blues and saturated colors
+	YCbCr                  // full range BT.601 luma and chroma as in JPEG
+)
+
+func (s Space) String() string {
+	switch s {
+	case RGB:
+		return "rgb"
+	case LinearRGB:
+		return "linear-rgb"
+	case Lab:
+		return "lab"
+	case OKLab:
+		return "oklab"
+	case YCbCr:
+		return "ycbcr"
+	default:
+		return fmt.Sprintf("Space(%d)", int(s))
+	}
+}
+
+// Valid report whether s is one of the spaces above
+func (s Space) Valid() bool {
+	return s >= RGB && s <= YCbCr
+}
+
+// FromRGB convert a gamma encoded sRGB color whose channels range from 0 to 1 to the space.
+// The coordinates are scaled so that the lightness of black is 0 and the one of white is 1,
+// the other axes keep the proportions of the space.
+func (s Space) FromRGB(c [3]float64) [3]float64 {
+	switch s {
+	case LinearRGB:
+		return [3]float64{ToLinear(c[0]), ToLinear(c[1]), ToLinear(c[2])}
+	case Lab:
+		return xyzToLab(linearToXYZ(ToLinear(c[0]), ToLinear(c[1]), ToLinear(c[2])))
+	case OKLab:
+		return linearToOKLab(ToLinear(c[0]), ToLinear(c[1]), ToLinear(c[2]))
+	case YCbCr:
+		y := 0.299*c[0] + 0.587*c[1] + 0.114*c[2]
+		return [3]float64{y, (c[2] - y) / 1.772, (c[0] - y) / 1.402}
+	default:
+		return c
+	}
+}
+
+// ToRGB is the inverse of FromRGB, colors out of the sRGB gamut are not clamped
+func (s Space) ToRGB(c [3]float64) [3]float64 {
+	switch s {
+	case LinearRGB:
+		return [3]float64{FromLinear(c[0]), FromLinear(c[1]), FromLinear(c[2])}
+	case Lab:
+		return encode(xyzToLinear(labToXYZ(c)))
+	case OKLab:
+		return encode(okLabToLinear(c))
+	case YCbCr:
+		return [3]float64{c[0] + 1.402*c[2], c[0] - 0.344136*c[1] - 0.714136*c[2], c[0] + 1.772*c[1]}
+	default:
+		return c
+	}
+}
+
+// Bounds return the lowest and highest coordinates of the sRGB gamut along each axis of the space
+func (s Space) Bounds() (min, max [3]float64) {
+	switch s {
+	case Lab:
+		return [3]float64{0, -0.8619, -1.0787}, [3]float64{1, 0.9824, 0.9448}
+	case OKLab:
+		return [3]float64{0, -0.2339, -0.3116}, [3]float64{1, 0.2763, 0.1986}
+	case YCbCr:
+		return [3]float64{0, -0.5, -0.5}, [3]float64{1, 0.5, 0.5}
+	default:
+		return [3]float64{0, 0, 0}, [3]float64{1, 1, 1}
+	}
+}
+
+// ToLinear decode a gamma encoded sRGB channel from 0 to 1 to linear light
+func ToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// FromLinear encode a linear light channel from 0 to 1 to gamma encoded sRGB
+func FromLinear(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// MeanDeltaE return the mean CIE76 difference between 8-bit sRGB pixels and their closest color
+// of the palette, 0 without pixels. It measures how closely the palette renders the pixels to the eye.
+func MeanDeltaE(pixels, palette [][3]int) float64 {
+	var sum, dist, minDist float64
+	var l [3]float64
+	var colors [][3]float64
+	var i, j int
+
+	if len(pixels) == 0 {
+		return 0
+	}
+	colors = make([][3]float64, len(palette))
+	for i = range palette {
+		colors[i] = lab8(palette[i])
+	}
+	for i = range pixels {
+		l = lab8(pixels[i])
+		minDist = math.Inf(1)
+		for j = range colors {
+			dist = (l[0]-colors[j][0])*(l[0]-colors[j][0]) + (l[1]-colors[j][1])*(l[1]-colors[j][1]) + (l[2]-colors[j][2])*(l[2]-colors[j][2])
+			if dist < minDist {
+				minDist = dist
+			}
+		}
+		sum += math.Sqrt(minDist) * 100
+	}
+	return sum / float64(len(pixels))
+}
+
+// lab8 convert an 8-bit sRGB color to CIELAB divided by 100
+func lab8(c [3]int) [3]float64 {
+	return Lab.FromRGB([3]float64{float64(c[0]) / 255, float64(c[1]) / 255, float64(c[2]) / 255})
+}
+
+func encode(c [3]float64) [3]float64 {
+	return [3]float64{FromLinear(c[0]), FromLinear(c[1]), FromLinear(c[2])}
+}
+
+// D65 white point
+const (
+	whiteX = 0.95047
+	whiteY = 1.0
+	whiteZ = 1.08883
+)
+
+func linearToXYZ(r, g, b float64) [3]float64 {
+	return [3]float64{
+		0.4124564*r + 0.3575761*g + 0.1804375*b,
+		0.2126729*r + 0.7151522*g + 0.0721750*b,
+		0.0193339*r + 0.1191920*g + 0.9503041*b,
+	}
+}
+
+func xyzToLinear(c [3]float64) [3]float64 {
+	return [3]float64{
+		3.2404542*c[0] - 1.5371385*c[1] - 0.4985314*c[2],
+		-0.9692660*c[0] + 1.8760108*c[1] + 0.0415560*c[2],
+		0.0556434*c[0] - 0.2040259*c[1] + 1.0572252*c[2],
+	}
+}
+
+// xyzToLab return L*, a* and b* divided by 100
+func xyzToLab(c [3]float64) [3]float64 {
+	fx, fy, fz := labF(c[0]/whiteX), labF(c[1]/whiteY), labF(c[2]/whiteZ)
+	return [3]float64{1.16*fy - 0.16, 5 * (fx - fy), 2 * (fy - fz)}
+}
+
+func labToXYZ(c [3]float64) [3]float64 {
+	fy := (c[0] + 0.16) / 1.16
+	fx, fz := fy+c[1]/5, fy-c[2]/2
+	return [3]float64{whiteX * labFInv(fx), whiteY * labFInv(fy), whiteZ * labFInv(fz)}
+}
+
+const labEpsilon = 6.0 / 29
+
+func labF(t float64) float64 {
+	if t > labEpsilon*labEpsilon*labEpsilon {
+		return math.Cbrt(t)
+	}
+	return t/(3*labEpsilon*labEpsilon) + 4.0/29
+}
+
+func labFInv(t float64) float64 {
+	if t > labEpsilon {
+		return t * t * t
+	}
+	return 3 * labEpsilon * labEpsilon * (t - 4.0/29)
+}
+
+func linearToOKLab(r, g, b float64) [3]float64 {
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+	return [3]float64{
+		0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+func okLabToLinear(c [3]float64) [3]float64 {
+	l := c[0] + 0.3963377774*c[1] + 0.2158037573*c[2]
+	m := c[0] - 0.1055613458*c[1] - 0.0638541728*c[2]
+	s := c[0] - 0.0894841775*c[1] - 1.2914855480*c[2]
+	l, m, s = l*l*l, m*m*m, s*s*s
+	return [3]float64{
+		4.0767416621*l - 3.3077115913*m + 0.2309699292*s,
+		-1.2684380046*l + 2.6097574011*m - 0.3413193965*s,
+		-0.0041960863*l - 0.7034186147*m + 1.7076147010*s,
+	}
+}
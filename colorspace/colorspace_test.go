@@ -0,0 +1,63 @@
+package colorspace
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	colors := [][3]float64{{0, 0, 0}, {1, 1, 1}, {1, 0, 0}, {0.2, 0.5, 0.9}, {0.01, 0.99, 0.3}}
+	for _, space := range []Space{RGB, LinearRGB, Lab, OKLab, YCbCr} {
+		if white := space.FromRGB([3]float64{1, 1, 1}); math.Abs(white[0]-1) > 1e-6 {
+			t.Errorf("%v: expected the lightness of white to be 1, got %v", space, white)
+		}
+		for _, c := range colors {
+			back := space.ToRGB(space.FromRGB(c))
+			for i := range c {
+				if math.Abs(back[i]-c[i]) > 1e-5 {
+					t.Errorf("%v: %v converts back to %v", space, c, back)
+					break
+				}
+			}
+		}
+	}
+
+	// CIELAB of sRGB red, divided by 100
+	if red := Lab.FromRGB([3]float64{1, 0, 0}); math.Abs(red[0]-0.5324) > 1e-4 || math.Abs(red[1]-0.8009) > 1e-4 || math.Abs(red[2]-0.6720) > 1e-4 {
+		t.Errorf("unexpected CIELAB red %v", red)
+	}
+	if Space(-1).Valid() || !YCbCr.Valid() {
+		t.Error("unexpected validity")
+	}
+}
+
+func TestBounds(t *testing.T) {
+	for _, space := range []Space{RGB, LinearRGB, Lab, OKLab, YCbCr} {
+		min, max := space.Bounds()
+		for r := 0; r <= 32; r++ {
+			for g := 0; g <= 32; g++ {
+				for b := 0; b <= 32; b++ {
+					c := space.FromRGB([3]float64{float64(r) / 32, float64(g) / 32, float64(b) / 32})
+					for i := range c {
+						if c[i] < min[i]-1e-6 || c[i] > max[i]+1e-6 {
+							t.Fatalf("%v: %v is out of the bounds %v %v", space, c, min, max)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestMeanDeltaE(t *testing.T) {
+	pixels := [][3]int{{0, 0, 0}, {255, 255, 255}}
+	if d := MeanDeltaE(pixels, pixels); d != 0 {
+		t.Errorf("expected no difference with the pixels as palette, got %v", d)
+	}
+	if d := MeanDeltaE(pixels, [][3]int{{0, 0, 0}}); math.Abs(d-50) > 1e-3 {
+		t.Errorf("expected white to be 100 away from black, got a mean of %v", d)
+	}
+	if d := MeanDeltaE(nil, pixels); d != 0 {
+		t.Errorf("expected 0 without pixels, got %v", d)
+	}
+}
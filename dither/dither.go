@@ -0,0 +1,227 @@
+package dither
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+)
+
+// Method selects how the quantization error is hidden when an image is remapped onto a palette
+type Method int
+
+const (
+	None              Method = iota // map every pixel to its own palette entry
+	FloydSteinberg                  // error diffusion to 4 neighbours
+	JarvisJudiceNinke               // error diffusion to 12 neighbours over 3 rows
+	Sierra                          // error diffusion to 10 neighbours over 3 rows
+	Atkinson                        // error diffusion of 3/4 of the error to 6 neighbours
+	Bayer2                          // ordered dithering with a 2x2 Bayer matrix
+	Bayer4                          // ordered dithering with a 4x4 Bayer matrix
+	Bayer8                          // ordered dithering with an 8x8 Bayer matrix
+	BlueNoise                       // ordered dithering with a 64x64 void-and-cluster blue noise mask
+	Riemersma                       // error diffusion along a Hilbert curve with a decaying error history
+)
+
+var names = [...]string{"none", "floyd-steinberg", "jarvis-judice-ninke", "sierra", "atkinson",
+	"bayer2", "bayer4", "bayer8", "blue-noise", "riemersma"}
+
+func (m Method) String() string {
+	if m < 0 || int(m) >= len(names) {
+		return fmt.Sprintf("Method(%d)", int(m))
+	}
+	return names[m]
+}
+
+// Options selects the dithering method and how it is applied
+type Options struct {
+	Method     Method
+	Strength   float64 // fraction of the error diffused, or of the threshold spread, from 0 to 1
+	Serpentine bool    // scan every other row from right to left, error diffusion only
+}
+
+// IndexFunc return the palette index of a color
+type IndexFunc func(c [3]int) int
+
+// Apply map every pixel of src onto the palette and store the resulting indices in dst,
+// dst covers the bounds of src and palette[i] is the color of index i.
+// index map a color to its palette entry, the quantizer that produced the palette usually knows best.
+func Apply(dst *image.Paletted, src *image.RGBA, palette [][3]int, index IndexFunc, opts Options) {
+	_ = ApplyContext(context.Background(), dst, src, palette, index, opts)
+}
+
+// ApplyContext is Apply that gives up with the context error once ctx is done,
+// the context is checked once per row of pixels
+func ApplyContext(ctx context.Context, dst *image.Paletted, src *image.RGBA, palette [][3]int, index IndexFunc, opts Options) error {
+	switch opts.Method {
+	case FloydSteinberg, JarvisJudiceNinke, Sierra, Atkinson:
+		return diffuse(ctx, dst, src, palette, index, kernels[opts.Method], opts)
+	case Bayer2:
+		return ordered(ctx, dst, src, index, bayer2[:], 2, spread(palette)*opts.Strength)
+	case Bayer4:
+		return ordered(ctx, dst, src, index, bayer4[:], 4, spread(palette)*opts.Strength)
+	case Bayer8:
+		return ordered(ctx, dst, src, index, bayer8[:], 8, spread(palette)*opts.Strength)
+	case BlueNoise:
+		return ordered(ctx, dst, src, index, blueNoise(), blueNoiseSize, spread(palette)*opts.Strength)
+	case Riemersma:
+		return riemersma(ctx, dst, src, palette, index, opts.Strength)
+	default:
+		return ordered(ctx, dst, src, index, []float64{0}, 1, 0)
+	}
+}
+
+// tap diffuses weight/divisor of the error to the pixel at (x+dx, y+dy)
+type tap struct {
+	dx, dy int
+	weight float64
+}
+
+type kernel struct {
+	taps    []tap
+	divisor float64
+	rows    int
+}
+
+var kernels = map[Method]kernel{
+	FloydSteinberg: {
+		taps:    []tap{{1, 0, 7}, {-1, 1, 3}, {0, 1, 5}, {1, 1, 1}},
+		divisor: 16,
+		rows:    2,
+	},
+	JarvisJudiceNinke: {
+		taps: []tap{{1, 0, 7}, {2, 0, 5},
+			{-2, 1, 3}, {-1, 1, 5}, {0, 1, 7}, {1, 1, 5}, {2, 1, 3},
+			{-2, 2, 1}, {-1, 2, 3}, {0, 2, 5}, {1, 2, 3}, {2, 2, 1}},
+		divisor: 48,
+		rows:    3,
+	},
+	Sierra: {
+		taps: []tap{{1, 0, 5}, {2, 0, 3},
+			{-2, 1, 2}, {-1, 1, 4}, {0, 1, 5}, {1, 1, 4}, {2, 1, 2},
+			{-1, 2, 2}, {0, 2, 3}, {1, 2, 2}},
+		divisor: 32,
+		rows:    3,
+	},
+	Atkinson: {
+		taps:    []tap{{1, 0, 1}, {2, 0, 1}, {-1, 1, 1}, {0, 1, 1}, {1, 1, 1}, {0, 2, 1}},
+		divisor: 8,
+		rows:    3,
+	},
+}
+
+// diffuse spread the error of every pixel over its unvisited neighbours
+func diffuse(ctx context.Context, dst *image.Paletted, src *image.RGBA, palette [][3]int, index IndexFunc, k kernel, opts Options) error {
+	var x, y, i, j, dir, offset int
+	var v [3]float64
+	var c [3]int
+	var e [3]float64
+	var errs [][][3]float64 // error of the current and next rows, padded by 2 pixels on both sides
+
+	bounds := src.Bounds()
+	width := bounds.Dx()
+	errs = make([][][3]float64, k.rows)
+	for i = range errs {
+		errs[i] = make([][3]float64, width+4)
+	}
+
+	for y = 0; y < bounds.Dy(); y++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		dir = 1
+		x = 0
+		if opts.Serpentine && y%2 == 1 {
+			dir, x = -1, width-1
+		}
+		for ; x >= 0 && x < width; x += dir {
+			offset = src.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+			for j = 0; j < 3; j++ {
+				v[j] = clamp(float64(src.Pix[offset+j]) + errs[0][x+2][j])
+				c[j] = int(v[j] + 0.5)
+			}
+			i = index(c)
+			dst.Pix[dst.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)] = uint8(i)
+
+			for j = 0; j < 3; j++ {
+				e[j] = (v[j] - float64(palette[i][j])) * opts.Strength / k.divisor
+			}
+			for _, t := range k.taps {
+				next := &errs[t.dy][x+t.dx*dir+2]
+				for j = 0; j < 3; j++ {
+					next[j] += e[j] * t.weight
+				}
+			}
+		}
+
+		// shift the rows up and clear the last one
+		first := errs[0]
+		copy(errs, errs[1:])
+		for i = range first {
+			first[i] = [3]float64{}
+		}
+		errs[len(errs)-1] = first
+	}
+	return nil
+}
+
+// ordered add the threshold matrix scaled by amount to every pixel before mapping it
+func ordered(ctx context.Context, dst *image.Paletted, src *image.RGBA, index IndexFunc, matrix []float64, n int, amount float64) error {
+	var x, y, j, offset int
+	var t float64
+	var c [3]int
+
+	bounds := src.Bounds()
+	for y = 0; y < bounds.Dy(); y++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		offset = src.PixOffset(bounds.Min.X, bounds.Min.Y+y)
+		for x = 0; x < bounds.Dx(); x++ {
+			t = matrix[(y%n)*n+x%n] * amount
+			for j = 0; j < 3; j++ {
+				c[j] = int(clamp(float64(src.Pix[offset+j])+t) + 0.5)
+			}
+			dst.Pix[dst.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)] = uint8(index(c))
+			offset += 4
+		}
+	}
+	return nil
+}
+
+// spread estimate the distance between neighbouring colors of the palette, ordered dithering
+// perturbs the pixels by up to half of it
+func spread(palette [][3]int) float64 {
+	var i, j int
+	var d, minDist, sum float64
+
+	if len(palette) < 2 {
+		return 0
+	}
+	for i = range palette {
+		minDist = math.Inf(1)
+		for j = range palette {
+			if i == j {
+				continue
+			}
+			d = math.Sqrt(float64((palette[i][0]-palette[j][0])*(palette[i][0]-palette[j][0]) +
+				(palette[i][1]-palette[j][1])*(palette[i][1]-palette[j][1]) +
+				(palette[i][2]-palette[j][2])*(palette[i][2]-palette[j][2])))
+			if d < minDist {
+				minDist = d
+			}
+		}
+		sum += minDist
+	}
+	return sum / float64(len(palette))
+}
+
+func clamp(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return v
+}
@@ -0,0 +1,122 @@
+package dither
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+var blackWhite = [][3]int{{0, 0, 0}, {255, 255, 255}}
+
+func nearest(c [3]int) int {
+	if c[0]+c[1]+c[2] >= 3*128 {
+		return 1
+	}
+	return 0
+}
+
+func gray(v uint8, w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = v, v, v, 255
+	}
+	return img
+}
+
+func TestBayer(t *testing.T) {
+	if !reflect.DeepEqual(bayer2, normalize([]int{0, 2, 3, 1})) {
+		t.Errorf("unexpected 2x2 Bayer matrix %v", bayer2)
+	}
+	if len(bayer8) != 64 {
+		t.Errorf("unexpected 8x8 Bayer matrix size %d", len(bayer8))
+	}
+}
+
+func TestBlueNoise(t *testing.T) {
+	ranks := voidAndCluster(16, blueNoiseSigma, blueNoiseSeed)
+	sorted := append([]int(nil), ranks...)
+	sort.Ints(sorted)
+	for i, r := range sorted {
+		if r != i {
+			t.Fatalf("ranks should be a permutation of 0..255, got %v", ranks)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	src := gray(96, 64, 64)
+	for m := FloydSteinberg; m <= Riemersma; m++ {
+		for _, serpentine := range []bool{false, true} {
+			dst := image.NewPaletted(src.Bounds(), color.Palette{color.Black, color.White})
+			Apply(dst, src, blackWhite, nearest, Options{Method: m, Strength: 1, Serpentine: serpentine})
+
+			white := 0
+			for _, i := range dst.Pix {
+				white += int(i)
+			}
+			// 96/255 of the pixels should turn white, Atkinson loses a quarter of the error
+			if share := float64(white) / float64(len(dst.Pix)); share < 0.25 || share > 0.5 {
+				t.Errorf("%v: unexpected share of white pixels %v", m, share)
+			}
+		}
+	}
+
+	dst := image.NewPaletted(src.Bounds(), color.Palette{color.Black, color.White})
+	Apply(dst, src, blackWhite, nearest, Options{Method: FloydSteinberg, Strength: 0})
+	for _, i := range dst.Pix {
+		if i != 0 {
+			t.Fatal("no error should be diffused with strength 0")
+		}
+	}
+}
+
+func TestGilbert(t *testing.T) {
+	for _, size := range [][2]int{{8, 8}, {1, 1}, {7, 1}, {1, 5}, {37, 23}, {6, 40}, {4096, 2}} {
+		seen := make(map[[2]int]bool)
+		px, py := 0, 0
+		gilbert(size[0], size[1], func(x, y int) bool {
+			if seen[[2]int{x, y}] || x < 0 || x >= size[0] || y < 0 || y >= size[1] {
+				t.Fatalf("%v: unexpected point (%d, %d)", size, x, y)
+			}
+			if len(seen) > 0 && (abs(x-px) > 1 || abs(y-py) > 1 || size[0]%2 == 0 && size[1]%2 == 0 && abs(x-px)+abs(y-py) != 1) {
+				t.Fatalf("%v: (%d, %d) is not adjacent to (%d, %d)", size, x, y, px, py)
+			}
+			seen[[2]int{x, y}] = true
+			px, py = x, y
+			return true
+		})
+		if len(seen) != size[0]*size[1] {
+			t.Errorf("%v: %d cells visited", size, len(seen))
+		}
+	}
+}
+
+func TestApplyContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	src := gray(96, 64, 64)
+	for m := None; m <= Riemersma; m++ {
+		dst := image.NewPaletted(src.Bounds(), color.Palette{color.Black, color.White})
+		if err := ApplyContext(ctx, dst, src, blackWhite, nearest, Options{Method: m, Strength: 1}); err != context.Canceled {
+			t.Errorf("%v: expected context.Canceled, got %v", m, err)
+		}
+	}
+}
+
+func BenchmarkBlueNoise(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = voidAndCluster(blueNoiseSize, blueNoiseSigma, blueNoiseSeed)
+	}
+}
+
+// BenchmarkRiemersma dithers a wide strip, the curve should not cover a square of its longer side
+func BenchmarkRiemersma(b *testing.B) {
+	src := gray(96, 16384, 2)
+	dst := image.NewPaletted(src.Bounds(), color.Palette{color.Black, color.White})
+	for i := 0; i < b.N; i++ {
+		Apply(dst, src, blackWhite, nearest, Options{Method: Riemersma, Strength: 1})
+	}
+}
@@ -0,0 +1,170 @@
+package dither
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// threshold matrices normalized to (-0.5, 0.5)
+var (
+	bayer2 = bayer(2)
+	bayer4 = bayer(4)
+	bayer8 = bayer(8)
+)
+
+// bayer build the n x n Bayer index matrix recursively, M(2n) = [4M 4M+2; 4M+3 4M+1]
+func bayer(n int) []float64 {
+	var x, y, v, size int
+
+	m := []int{0}
+	for size = 1; size < n; size *= 2 {
+		next := make([]int, 4*size*size)
+		for y = 0; y < size; y++ {
+			for x = 0; x < size; x++ {
+				v = 4 * m[y*size+x]
+				next[y*2*size+x] = v
+				next[y*2*size+x+size] = v + 2
+				next[(y+size)*2*size+x] = v + 3
+				next[(y+size)*2*size+x+size] = v + 1
+			}
+		}
+		m = next
+	}
+	return normalize(m)
+}
+
+func normalize(ranks []int) []float64 {
+	matrix := make([]float64, len(ranks))
+	for i, r := range ranks {
+		matrix[i] = (float64(r)+0.5)/float64(len(ranks)) - 0.5
+	}
+	return matrix
+}
+
+const (
+	blueNoiseSize  = 64
+	blueNoiseSigma = 1.5
+	blueNoiseSeed  = 1
+)
+
+var (
+	blueNoiseOnce   sync.Once
+	blueNoiseMatrix []float64
+)
+
+// blueNoise return the blue noise threshold matrix, generated on first use
+func blueNoise() []float64 {
+	blueNoiseOnce.Do(func() {
+		blueNoiseMatrix = normalize(voidAndCluster(blueNoiseSize, blueNoiseSigma, blueNoiseSeed))
+	})
+	return blueNoiseMatrix
+}
+
+// voidAndCluster rank the cells of an n x n torus with Ulichney's void-and-cluster method,
+// the minority pixels are spread as evenly as possible at every rank
+func voidAndCluster(n int, sigma float64, seed int64) []int {
+	var i, dx, dy, ones int
+	size := n * n
+
+	// gaussian energy of every toroidal offset
+	kernel := make([]float64, size)
+	for dy = 0; dy < n; dy++ {
+		for dx = 0; dx < n; dx++ {
+			ddx, ddy := float64(dx), float64(dy)
+			if dx > n/2 {
+				ddx = float64(n - dx)
+			}
+			if dy > n/2 {
+				ddy = float64(n - dy)
+			}
+			kernel[dy*n+dx] = math.Exp(-(ddx*ddx + ddy*ddy) / (2 * sigma * sigma))
+		}
+	}
+
+	pattern := make([]bool, size)
+	energy := make([]float64, size)
+	toggle := func(p []bool, e []float64, pos int, on bool) {
+		px, py := pos%n, pos/n
+		sign := 1.0
+		if !on {
+			sign = -1
+		}
+		p[pos] = on
+		for y := 0; y < n; y++ {
+			row := ((y - py + n) % n) * n
+			for x := 0; x < n; x++ {
+				e[y*n+x] += sign * kernel[row+(x-px+n)%n]
+			}
+		}
+	}
+	// extreme return the tightest cluster (max energy) or the largest void (min energy) among cells set to value
+	extreme := func(p []bool, e []float64, value, max bool) int {
+		best := -1
+		for i := range p {
+			if p[i] != value {
+				continue
+			}
+			if best < 0 || (max && e[i] > e[best]) || (!max && e[i] < e[best]) {
+				best = i
+			}
+		}
+		return best
+	}
+
+	// initial binary pattern, 10% of the cells set at random
+	rng := rand.New(rand.NewSource(seed))
+	for ones < size/10 {
+		if i = rng.Intn(size); !pattern[i] {
+			toggle(pattern, energy, i, true)
+			ones++
+		}
+	}
+
+	// move the tightest cluster into the largest void until it lands where it came from
+	for {
+		cluster := extreme(pattern, energy, true, true)
+		toggle(pattern, energy, cluster, false)
+		void := extreme(pattern, energy, false, false)
+		toggle(pattern, energy, void, true)
+		if void == cluster {
+			break
+		}
+	}
+
+	rank := make([]int, size)
+	initial := append([]bool(nil), pattern...)
+	initialEnergy := append([]float64(nil), energy...)
+
+	// phase 1, rank the initial pattern by removing its tightest clusters
+	for r := ones - 1; r >= 0; r-- {
+		i = extreme(pattern, energy, true, true)
+		toggle(pattern, energy, i, false)
+		rank[i] = r
+	}
+
+	// phase 2, fill the largest voids up to half of the cells
+	pattern, energy = initial, initialEnergy
+	for r := ones; r < size/2; r++ {
+		i = extreme(pattern, energy, false, false)
+		toggle(pattern, energy, i, true)
+		rank[i] = r
+	}
+
+	// phase 3, the unset cells are now the minority, rank their tightest clusters first
+	inverse := make([]bool, size)
+	for i = range energy {
+		energy[i] = 0
+	}
+	for i = range pattern {
+		if !pattern[i] {
+			toggle(inverse, energy, i, true)
+		}
+	}
+	for r := size / 2; r < size; r++ {
+		i = extreme(inverse, energy, true, true)
+		toggle(inverse, energy, i, false)
+		rank[i] = r
+	}
+	return rank
+}
@@ -0,0 +1,147 @@
+package dither
+
+import (
+	"context"
+	"image"
+	"math"
+)
+
+const (
+	historySize  = 16 // number of errors remembered along the curve
+	historyRatio = 16 // ratio between the weights of the newest and the oldest error
+)
+
+// riemersma visit the pixels along a generalized Hilbert curve and add a weighted history of the last
+// errors to every pixel, the newest error weighs the most. The context is checked once per row of pixels.
+func riemersma(ctx context.Context, dst *image.Paletted, src *image.RGBA, palette [][3]int, index IndexFunc, strength float64) error {
+	var i, j, n, offset int
+	var weights [historySize]float64
+	var history [historySize][3]float64
+	var v [3]float64
+	var c [3]int
+	var sum float64
+	var err error
+
+	bounds := src.Bounds()
+	if bounds.Empty() {
+		return ctx.Err()
+	}
+
+	for i = range weights {
+		weights[i] = math.Pow(historyRatio, float64(i)/(historySize-1)) / historyRatio
+		sum += weights[i]
+	}
+	for i = range weights {
+		weights[i] /= sum
+	}
+
+	gilbert(bounds.Dx(), bounds.Dy(), func(x, y int) bool {
+		if n++; n%bounds.Dx() == 0 {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+		}
+
+		offset = src.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+		for j = 0; j < 3; j++ {
+			v[j] = float64(src.Pix[offset+j])
+			for i = range history {
+				v[j] += history[i][j] * weights[i] * strength
+			}
+			v[j] = clamp(v[j])
+			c[j] = int(v[j] + 0.5)
+		}
+		i = index(c)
+		dst.Pix[dst.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)] = uint8(i)
+
+		copy(history[:], history[1:])
+		for j = 0; j < 3; j++ {
+			history[historySize-1][j] = v[j] - float64(palette[i][j])
+		}
+		return true
+	})
+	return err
+}
+
+// gilbert visit every cell of a width x height area once along Jakub Červený's generalized Hilbert curve,
+// consecutive cells are neighbours but for a single diagonal step in some areas of odd sides.
+// It stops as soon as visit return false.
+func gilbert(width, height int, visit func(x, y int) bool) {
+	if width >= height {
+		gilbert2d(0, 0, width, 0, 0, height, visit)
+	} else {
+		gilbert2d(0, 0, 0, height, width, 0, visit)
+	}
+}
+
+// gilbert2d cover the area spanned from (x, y) by the major axis (ax, ay) and the minor one (bx, by),
+// and report whether the visit went on until its end
+func gilbert2d(x, y, ax, ay, bx, by int, visit func(x, y int) bool) bool {
+	var i int
+
+	w, h := abs(ax+ay), abs(bx+by)
+	dax, day := sign(ax), sign(ay)
+	dbx, dby := sign(bx), sign(by)
+
+	if h == 1 {
+		for i = 0; i < w; i++ {
+			if !visit(x, y) {
+				return false
+			}
+			x, y = x+dax, y+day
+		}
+		return true
+	}
+	if w == 1 {
+		for i = 0; i < h; i++ {
+			if !visit(x, y) {
+				return false
+			}
+			x, y = x+dbx, y+dby
+		}
+		return true
+	}
+
+	ax2, ay2 := floorHalf(ax), floorHalf(ay)
+	bx2, by2 := floorHalf(bx), floorHalf(by)
+	w2, h2 := abs(ax2+ay2), abs(bx2+by2)
+
+	if 2*w > 3*h {
+		// long area, split it in two along the major axis
+		if w2%2 != 0 && w > 2 {
+			ax2, ay2 = ax2+dax, ay2+day
+		}
+		return gilbert2d(x, y, ax2, ay2, bx, by, visit) &&
+			gilbert2d(x+ax2, y+ay2, ax-ax2, ay-ay2, bx, by, visit)
+	}
+
+	// up, across and down
+	if h2%2 != 0 && h > 2 {
+		bx2, by2 = bx2+dbx, by2+dby
+	}
+	return gilbert2d(x, y, bx2, by2, ax2, ay2, visit) &&
+		gilbert2d(x+bx2, y+by2, ax, ay, bx-bx2, by-by2, visit) &&
+		gilbert2d(x+(ax-dax)+(bx2-dbx), y+(ay-day)+(by2-dby), -bx2, -by2, -(ax-ax2), -(ay-ay2), visit)
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sign(v int) int {
+	if v < 0 {
+		return -1
+	}
+	if v > 0 {
+		return 1
+	}
+	return 0
+}
+
+// floorHalf divide v by 2 rounding down, negative values included
+func floorHalf(v int) int {
+	return v >> 1
+}
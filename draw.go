@@ -0,0 +1,89 @@
+package color_thief
+
+import (
+	"color-thief/dither"
+	"color-thief/quantizer"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+)
+
+// Wu is a draw.Quantizer building the palette with Xiaolin Wu's color quantizer, it plugs into
+// image/gif: gif.Encode(w, img, &gif.Options{Quantizer: color_thief.Wu{}})
+type Wu struct {
+	Options []Option // tune the palette extraction, the number of colors is set by the encoder
+}
+
+// Quantize append up to cap(p) - len(p) colors of the image to p
+func (q Wu) Quantize(p color.Palette, m image.Image) color.Palette {
+	return quantizePalette(quantizer.Wu, q.Options, p, m)
+}
+
+// WSM is a draw.Quantizer building the palette with the weighted sort-means refinement of wu
+type WSM struct {
+	Options []Option // tune the palette extraction, the number of colors is set by the encoder
+}
+
+// Quantize append up to cap(p) - len(p) colors of the image to p
+func (q WSM) Quantize(p color.Palette, m image.Image) color.Palette {
+	return quantizePalette(quantizer.WSM, q.Options, p, m)
+}
+
+// quantizePalette append the palette of the image to p, falling back to the Plan 9 palette
+// like image/gif does when the image cannot be quantized
+func quantizePalette(algorithm string, opts []Option, p color.Palette, m image.Image) color.Palette {
+	k := cap(p) - len(p)
+	if k <= 0 {
+		return p
+	}
+
+	opts = append(append([]Option(nil), opts...), WithAlgorithm(algorithm), WithColors(k))
+	colors, err := GetPaletteWithOptions(m, opts...)
+	if err != nil {
+		if k > len(palette.Plan9) {
+			k = len(palette.Plan9)
+		}
+		return append(p, palette.Plan9[:k]...)
+	}
+	return append(p, colors.Colors()...)
+}
+
+// Ditherer is a draw.Drawer mapping the source onto the palette of a paletted destination with dithering,
+// it plugs into image/gif along with Wu or WSM. A zero Strength disables the dithering.
+type Ditherer dither.Options
+
+// Draw map the pixels of src from sp onto the palette of dst within r,
+// destinations other than *image.Paletted are drawn without dithering
+func (d Ditherer) Draw(dst draw.Image, r image.Rectangle, src image.Image, sp image.Point) {
+	var y int
+
+	pm, ok := dst.(*image.Paletted)
+	if !ok || len(pm.Palette) == 0 {
+		draw.Draw(dst, r, src, sp, draw.Src)
+		return
+	}
+
+	// clip r to both images, keeping r.Min aligned with sp
+	orig := r.Min
+	r = r.Intersect(pm.Bounds()).Intersect(src.Bounds().Add(orig.Sub(sp)))
+	if r.Empty() {
+		return
+	}
+	sp = sp.Add(r.Min.Sub(orig))
+
+	colors := make([][3]int, len(pm.Palette))
+	for i, c := range pm.Palette {
+		cr, cg, cb, _ := c.RGBA()
+		colors[i] = [3]int{int(cr >> 8), int(cg >> 8), int(cb >> 8)}
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, sp, draw.Src)
+	tmp := image.NewPaletted(rgba.Bounds(), pm.Palette)
+	dither.Apply(tmp, rgba, colors, dither.IndexFunc(quantizer.Nearest(colors)), dither.Options(d))
+
+	for y = 0; y < r.Dy(); y++ {
+		copy(pm.Pix[pm.PixOffset(r.Min.X, r.Min.Y+y):], tmp.Pix[tmp.PixOffset(0, y):tmp.PixOffset(r.Dx(), y)])
+	}
+}
@@ -0,0 +1,64 @@
+package color_thief
+
+import (
+	"bytes"
+	"color-thief/dither"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"testing"
+)
+
+func TestGIF(t *testing.T) {
+	for _, q := range []draw.Quantizer{Wu{}, WSM{Options: []Option{WithMaxIterations(10)}}} {
+		var buf bytes.Buffer
+		opts := &gif.Options{
+			NumColors: 32,
+			Quantizer: q,
+			Drawer:    Ditherer{Method: dither.FloydSteinberg, Strength: 1, Serpentine: true},
+		}
+		if err := gif.Encode(&buf, img, opts); err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := gif.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pm := decoded.(*image.Paletted)
+		if pm.Bounds() != img.Bounds() || len(pm.Palette) != 32 {
+			t.Errorf("%T: unexpected gif %v with %d colors", q, pm.Bounds(), len(pm.Palette))
+		}
+	}
+}
+
+func TestQuantizerCapacity(t *testing.T) {
+	p := Wu{}.Quantize(make(color.Palette, 1, 5), img)
+	if len(p) != 5 {
+		t.Errorf("expected 4 colors to be appended, got %d", len(p)-1)
+	}
+
+	// an image without pixels falls back to the Plan 9 palette
+	if p = (WSM{}).Quantize(make(color.Palette, 0, 8), image.NewRGBA(image.Rect(0, 0, 0, 0))); len(p) != 8 {
+		t.Errorf("expected the Plan 9 fallback, got %d colors", len(p))
+	}
+}
+
+func TestDithererOffset(t *testing.T) {
+	dst := image.NewPaletted(image.Rect(0, 0, 20, 20), color.Palette{color.Black, color.White})
+	src := image.NewUniform(color.White)
+	Ditherer{}.Draw(dst, image.Rect(10, 10, 30, 30), src, image.Point{})
+
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			want := uint8(0)
+			if x >= 10 && y >= 10 {
+				want = 1
+			}
+			if got := dst.ColorIndexAt(x, y); got != want {
+				t.Fatalf("pixel (%d, %d): expected index %d, got %d", x, y, want, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package color_thief
+
+import (
+	"color-thief/helper"
+	"color-thief/quantizer"
+	"errors"
+)
+
+var (
+	// ErrInvalidColorCount is returned when less than one color is requested, or more than the result can hold
+	ErrInvalidColorCount = errors.New("invalid number of colors")
+	// ErrUnknownAlgorithm is returned when no quantizer is registered under the requested name
+	ErrUnknownAlgorithm = errors.New("unknown algorithm")
+	// ErrInvalidOption is returned when an option is out of its valid range
+	ErrInvalidOption = errors.New("invalid option")
+	// ErrUnsupportedColorSpace is returned when the quantizer cannot cluster in the requested color space
+	ErrUnsupportedColorSpace = quantizer.ErrUnsupportedColorSpace
+	// ErrEmptyImage is returned when the image has no pixel to take into account,
+	// such as a 0x0 or a fully transparent image
+	ErrEmptyImage = helper.ErrEmptyImage
+	// ErrLimitExceeded is returned when an image to decode is larger than the Limits allow
+	ErrLimitExceeded = helper.ErrLimitExceeded
+	// ErrEmptyPalette is returned when there is no color to print
+	ErrEmptyPalette = errors.New("colors empty")
+	// ErrDecode matches every DecodeError with errors.Is
+	ErrDecode = errors.New("cannot decode image")
+)
+
+// DecodeError reports that the input could not be decoded as an image, Err is the codec error
+type DecodeError struct {
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return ErrDecode.Error() + ": " + e.Err.Error()
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Is report whether target is ErrDecode
+func (e *DecodeError) Is(target error) bool {
+	return target == ErrDecode
+}
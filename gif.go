@@ -0,0 +1,107 @@
+package color_thief
+
+import (
+	"bytes"
+	"color-thief/helper"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"math/rand"
+)
+
+// gifSamples bounds the pixels kept for the palette of a whole animation, past it the frames
+// are represented by a uniform sample of their pixels so that memory does not grow with the frame count
+const gifSamples = 1 << 20
+
+// GIFPalette is the palette of an animation along with the palette of each of its frames
+type GIFPalette struct {
+	Palette Palette   // colors of the whole animation, from the pixels of every frame
+	Frames  []Palette // colors of each frame when WithFramePalettes is set, nil for a fully transparent frame
+}
+
+// GetPaletteFromGIF decode every frame of an animated GIF and return the palette of the whole animation.
+// The frames are composited and disposed of the way a browser plays them, and each one is sampled
+// as set by the options, at most gifSamples of these pixels are kept across frames. Unless WithAlphaThreshold
+// or WithBackground is given, transparent pixels are skipped so that a blank fade-in does not count as black.
+func GetPaletteFromGIF(r io.Reader, opts ...Option) (*GIFPalette, error) {
+	return GetPaletteFromGIFContext(context.Background(), r, opts...)
+}
+
+// GetPaletteFromGIFContext is GetPaletteFromGIF that gives up once ctx is done
+func GetPaletteFromGIFContext(ctx context.Context, r io.Reader, opts ...Option) (*GIFPalette, error) {
+	var samples *reservoir
+
+	o := newOptions(opts)
+	if !o.alphaSet && o.Background == nil {
+		o.AlphaThreshold = 1
+	}
+	q, err := o.quantizer()
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := helper.DecodeGIFLimits(ctx, r, o.Limits)
+	if err != nil {
+		if ctx.Err() == nil && !errors.Is(err, ErrLimitExceeded) && !errors.Is(err, ErrEmptyImage) {
+			err = &DecodeError{Err: err}
+		}
+		return nil, err
+	}
+
+	result := &GIFPalette{}
+	if o.FramePalettes {
+		result.Frames = make([]Palette, len(g.Image))
+	}
+	samples = &reservoir{size: gifSamples, rng: rand.New(rand.NewSource(1))}
+	s := o.sampling()
+	err = helper.ComposeGIF(g, func(i int, canvas *image.RGBA) error {
+		var err error
+
+		frame := helper.SamplingPixelsFromImage(canvas, s)
+		samples.add(frame)
+		if o.FramePalettes && len(frame) > 0 {
+			result.Frames[i], _, err = quantize(ctx, q, frame, &o, false)
+		} else {
+			err = ctx.Err()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(samples.pixels) == 0 {
+		return nil, ErrEmptyImage
+	}
+
+	if result.Palette, _, err = quantize(ctx, q, samples.pixels, &o, false); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetPaletteFromGIFBytes is GetPaletteFromGIF reading the encoded animation from data
+func GetPaletteFromGIFBytes(data []byte, opts ...Option) (*GIFPalette, error) {
+	return GetPaletteFromGIFContext(context.Background(), bytes.NewReader(data), opts...)
+}
+
+// reservoir keep a uniform sample of at most size of the pixels added to it
+type reservoir struct {
+	pixels [][3]int
+	size   int
+	seen   int
+	rng    *rand.Rand
+}
+
+func (r *reservoir) add(pixels [][3]int) {
+	var i, j int
+
+	for i = range pixels {
+		r.seen++
+		if len(r.pixels) < r.size {
+			r.pixels = append(r.pixels, pixels[i])
+		} else if j = r.rng.Intn(r.seen); j < r.size {
+			r.pixels[j] = pixels[i]
+		}
+	}
+}
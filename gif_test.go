@@ -0,0 +1,101 @@
+package color_thief
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"math/rand"
+	"testing"
+)
+
+func TestGetPaletteFromGIF(t *testing.T) {
+	pal := color.Palette{color.RGBA{}, color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}, color.RGBA{G: 255, A: 255}}
+	frame := func(r image.Rectangle, index uint8) *image.Paletted {
+		f := image.NewPaletted(r, pal)
+		for i := range f.Pix {
+			f.Pix[i] = index
+		}
+		return f
+	}
+	encode := func(g *gif.GIF) []byte {
+		var buf bytes.Buffer
+		g.Delay = make([]int, len(g.Image))
+		g.Config = image.Config{Width: 10, Height: 10}
+		if err := gif.EncodeAll(&buf, g); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	// a blank fade-in, a red frame cleared once shown, then a blue left half
+	data := encode(&gif.GIF{
+		Image:    []*image.Paletted{frame(image.Rect(0, 0, 10, 10), 0), frame(image.Rect(0, 0, 10, 10), 1), frame(image.Rect(0, 0, 5, 10), 2)},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone},
+	})
+	result, err := GetPaletteFromGIFBytes(data, WithStride(1), WithColors(4), WithFramePalettes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Palette) != 2 || result.Palette[0].Hex() != "#ff0000" || result.Palette[0].Population != 100 ||
+		result.Palette[1].Hex() != "#0000ff" || result.Palette[1].Population != 50 {
+		t.Errorf("unexpected palette %v", result.Palette)
+	}
+	if len(result.Frames) != 3 || result.Frames[0] != nil || result.Frames[1][0].Hex() != "#ff0000" ||
+		len(result.Frames[2]) != 1 || result.Frames[2][0].Hex() != "#0000ff" {
+		t.Errorf("unexpected frame palettes %v", result.Frames)
+	}
+
+	// the blue frame is undone before the green one is drawn over the red background
+	data = encode(&gif.GIF{
+		Image:    []*image.Paletted{frame(image.Rect(0, 0, 10, 10), 1), frame(image.Rect(0, 0, 5, 5), 2), frame(image.Rect(5, 5, 10, 10), 3)},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalNone},
+	})
+	if result, err = GetPaletteFromGIFBytes(data, WithStride(1), WithColors(4), WithFramePalettes()); err != nil {
+		t.Fatal(err)
+	}
+	if last := result.Frames[2]; len(last) != 2 || last[0].Hex() != "#ff0000" || last[0].Population != 75 || last[1].Hex() != "#00ff00" {
+		t.Errorf("unexpected last frame palette %v", last)
+	}
+	if result.Palette[0].Population != 100+75+75 {
+		t.Errorf("unexpected palette %v", result.Palette)
+	}
+
+	// an explicit threshold of 0 keeps the transparent pixels of the first frame
+	data = encode(&gif.GIF{
+		Image:    []*image.Paletted{frame(image.Rect(0, 0, 10, 10), 0), frame(image.Rect(0, 0, 10, 10), 1)},
+		Disposal: []byte{gif.DisposalBackground, gif.DisposalNone},
+	})
+	if result, err = GetPaletteFromGIFBytes(data, WithStride(1), WithColors(4), WithAlphaThreshold(0)); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Palette) != 2 || result.Palette[0].Population != 100 || result.Palette[1].Population != 100 {
+		t.Errorf("expected the transparent frame to count, got %v", result.Palette)
+	}
+}
+
+func TestGIFLimits(t *testing.T) {
+	var buf bytes.Buffer
+
+	// a few bytes declaring a 4000x4000 screen and 100 frames of a single pixel
+	g := &gif.GIF{Config: image.Config{Width: 4000, Height: 4000}}
+	for i := 0; i < 100; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black}))
+		g.Delay = append(g.Delay, 0)
+	}
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetPaletteFromGIFBytes(buf.Bytes()); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected ErrLimitExceeded, got %v", err)
+	}
+
+	r := &reservoir{size: 100, rng: rand.New(rand.NewSource(1))}
+	for i := 0; i < 50; i++ {
+		r.add(make([][3]int, 20))
+	}
+	if len(r.pixels) != 100 || r.seen != 1000 {
+		t.Errorf("expected 100 of 1000 pixels kept, got %d of %d", len(r.pixels), r.seen)
+	}
+}
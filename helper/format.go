@@ -0,0 +1,156 @@
+package helper
+
+import (
+	"image"
+	"image/color"
+)
+
+// Layout is the arrangement of the pixels in a raw buffer
+type Layout int
+
+const (
+	RGBA   Layout = iota // 4 bytes per pixel, not premultiplied by alpha as in the ImageData of a canvas
+	BGRA                 // 4 bytes per pixel in blue, green, red, alpha order, not premultiplied by alpha
+	RGB24                // 3 bytes per pixel
+	RGB565               // 2 bytes per pixel, little-endian with red in the 5 high bits
+	Gray8                // 1 byte per pixel
+	YUV420               // I420: the Y plane followed by the U and V planes subsampled by 2 in both directions
+	NV12                 // the Y plane followed by a plane of interleaved U and V samples subsampled by 2 in both directions
+)
+
+func (l Layout) String() string {
+	switch l {
+	case RGBA:
+		return "rgba"
+	case BGRA:
+		return "bgra"
+	case RGB24:
+		return "rgb24"
+	case RGB565:
+		return "rgb565"
+	case Gray8:
+		return "gray8"
+	case YUV420:
+		return "yuv420"
+	case NV12:
+		return "nv12"
+	}
+	return "unknown"
+}
+
+// PixelFormat describes a raw pixel buffer. YUV samples are full-range BT.601 as in JPEG.
+type PixelFormat struct {
+	Layout       Layout
+	Stride       int // bytes from the start of a row to the next one, of the Y plane for YUV layouts, 0 for packed rows
+	ChromaStride int // bytes from a row of the chroma planes of YUV layouts to the next one, 0 for packed rows
+}
+
+// BufferSize return the number of bytes of a width x height buffer of the format,
+// or 0 when the strides are too short for the width or the layout is unknown
+func (f PixelFormat) BufferSize(width, height int) int {
+	stride, chromaStride, ok := f.strides(width)
+	if !ok {
+		return 0
+	}
+	switch f.Layout {
+	case YUV420:
+		return stride*height + 2*chromaStride*((height+1)/2)
+	case NV12:
+		return stride*height + chromaStride*((height+1)/2)
+	}
+	return stride * height
+}
+
+// strides return the row strides of the luma or pixel plane and of the chroma planes
+func (f PixelFormat) strides(width int) (int, int, bool) {
+	var packed, chromaPacked int
+
+	switch f.Layout {
+	case RGBA, BGRA:
+		packed = width * 4
+	case RGB24:
+		packed = width * 3
+	case RGB565:
+		packed = width * 2
+	case Gray8:
+		packed = width
+	case YUV420:
+		packed, chromaPacked = width, (width+1)/2
+	case NV12:
+		packed, chromaPacked = width, (width+1)/2*2
+	default:
+		return 0, 0, false
+	}
+
+	stride, chromaStride := f.Stride, f.ChromaStride
+	if stride == 0 {
+		stride = packed
+	}
+	if chromaStride == 0 {
+		chromaStride = chromaPacked
+	}
+	return stride, chromaStride, stride >= packed && chromaStride >= chromaPacked
+}
+
+// SamplingPixelsFormat collect the pixels picked by s from a raw buffer of the format, sparing a conversion
+// to RGBA first. Nothing is sampled from a buffer shorter than f.BufferSize(width, height).
+// At a Depth of 16 the 8-bit channels are widened to range from 0 to 65535.
+func SamplingPixelsFormat(src []uint8, width, height int, f PixelFormat, s Sampling) [][3]int {
+	var at pixelFunc[uint8]
+
+	r := image.Rect(0, 0, width, height)
+	if !s.Region.Empty() {
+		r = r.Intersect(s.Region)
+	}
+	size := f.BufferSize(width, height)
+	if r.Empty() || size == 0 || len(src) < size {
+		return [][3]int{}
+	}
+
+	stride, chromaStride, _ := f.strides(width)
+	min := r.Min
+	switch f.Layout {
+	case RGBA:
+		at = rgbaAt(src[min.Y*stride+min.X*4:], stride)
+	case BGRA:
+		at = func(x, y int) (uint8, uint8, uint8, uint8) {
+			offset := (min.Y+y)*stride + (min.X+x)*4
+			return src[offset+2], src[offset+1], src[offset], src[offset+3]
+		}
+	case RGB24:
+		at = func(x, y int) (uint8, uint8, uint8, uint8) {
+			offset := (min.Y+y)*stride + (min.X+x)*3
+			return src[offset], src[offset+1], src[offset+2], 255
+		}
+	case RGB565:
+		at = func(x, y int) (uint8, uint8, uint8, uint8) {
+			offset := (min.Y+y)*stride + (min.X+x)*2
+			v := uint16(src[offset]) | uint16(src[offset+1])<<8
+			r, g, b := uint8(v>>11), uint8(v>>5&0x3f), uint8(v&0x1f)
+			return r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2, 255
+		}
+	case Gray8:
+		at = func(x, y int) (uint8, uint8, uint8, uint8) {
+			v := src[(min.Y+y)*stride+min.X+x]
+			return v, v, v, 255
+		}
+	case YUV420:
+		u := src[stride*height:]
+		v := u[chromaStride*((height+1)/2):]
+		at = func(x, y int) (uint8, uint8, uint8, uint8) {
+			x, y = min.X+x, min.Y+y
+			c := y/2*chromaStride + x/2
+			r, g, b := color.YCbCrToRGB(src[y*stride+x], u[c], v[c])
+			return r, g, b, 255
+		}
+	case NV12:
+		uv := src[stride*height:]
+		at = func(x, y int) (uint8, uint8, uint8, uint8) {
+			x, y = min.X+x, min.Y+y
+			c := y/2*chromaStride + x/2*2
+			r, g, b := color.YCbCrToRGB(src[y*stride+x], uv[c], uv[c+1])
+			return r, g, b, 255
+		}
+	}
+	return collectDepth(at, r.Dx(), r.Dy(), s, false)
+}
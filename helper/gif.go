@@ -0,0 +1,120 @@
+package helper
+
+import (
+	"image"
+	"image/gif"
+)
+
+// ComposeGIF render the frames of the animation one after the other the way a browser does,
+// disposing of each frame as it asks for, and call visit with the canvas once each frame is drawn.
+// The canvas is reused between calls, visit must not retain it.
+func ComposeGIF(g *gif.GIF, visit func(i int, canvas *image.RGBA) error) error {
+	var previous []uint8
+	var disposal byte
+
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	for i, frame := range g.Image {
+		disposal = 0
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = append(previous[:0], canvas.Pix...)
+		}
+
+		bounds := frame.Bounds().Intersect(canvas.Rect)
+		drawPaletted(canvas, frame, bounds)
+		if err := visit(i, canvas); err != nil {
+			return err
+		}
+
+		switch disposal {
+		case gif.DisposalBackground:
+			// browsers restore the area to transparent rather than to the background color
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				row := canvas.Pix[canvas.PixOffset(bounds.Min.X, y):canvas.PixOffset(bounds.Max.X, y)]
+				for j := range row {
+					row[j] = 0
+				}
+			}
+		case gif.DisposalPrevious:
+			copy(canvas.Pix, previous)
+		}
+	}
+	return nil
+}
+
+// drawPaletted draw the frame over the canvas within bounds, transparent pixels keep the canvas as is
+func drawPaletted(canvas *image.RGBA, frame *image.Paletted, bounds image.Rectangle) {
+	var x, y, offset int
+	var a uint32
+	var c [4]uint8
+
+	colors := make([][4]uint8, len(frame.Palette))
+	for i, pc := range frame.Palette {
+		cr, cg, cb, ca := pc.RGBA()
+		colors[i] = [4]uint8{uint8(cr >> 8), uint8(cg >> 8), uint8(cb >> 8), uint8(ca >> 8)}
+	}
+
+	for y = bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x = bounds.Min.X; x < bounds.Max.X; x++ {
+			index := int(frame.Pix[frame.PixOffset(x, y)])
+			if index >= len(colors) {
+				continue
+			}
+			c, offset = colors[index], canvas.PixOffset(x, y)
+			switch c[3] {
+			case 0:
+			case 255:
+				copy(canvas.Pix[offset:offset+4], c[:])
+			default:
+				// premultiplied over, as draw.Over does
+				a = 255 - uint32(c[3])
+				for j := 0; j < 4; j++ {
+					canvas.Pix[offset+j] = uint8(uint32(c[j]) + (uint32(canvas.Pix[offset+j])*a+127)/255)
+				}
+			}
+		}
+	}
+}
+
+// countGIFFrames walk the blocks of an encoded GIF and return its logical screen size along with
+// the number of image descriptors, a truncated or malformed stream is counted up to where it breaks
+func countGIFFrames(data []byte) (int, int, int) {
+	var width, height, frames int
+	var i int
+
+	// header and logical screen descriptor
+	if len(data) < 13 {
+		return 0, 0, 0
+	}
+	width = int(data[6]) | int(data[7])<<8
+	height = int(data[8]) | int(data[9])<<8
+	i = 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&7 + 1)
+	}
+
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // extension: introducer, label and sub-blocks
+			i += 2
+		case 0x2c: // image descriptor, local color table, LZW code size and sub-blocks
+			if i+10 > len(data) {
+				return width, height, frames
+			}
+			frames++
+			if data[i+9]&0x80 != 0 {
+				i += 3 << (data[i+9]&7 + 1)
+			}
+			i += 11
+		default: // trailer or garbage
+			return width, height, frames
+		}
+		for i < len(data) && data[i] != 0 {
+			i += int(data[i]) + 1
+		}
+		i++
+	}
+	return width, height, frames
+}
@@ -1,62 +1,21 @@
 package helper
 
 import (
+	_ "color-thief/codec/bmp"
+	_ "color-thief/codec/farbfeld"
+	_ "color-thief/codec/netpbm"
+	_ "color-thief/codec/qoi"
+	"context"
 	"fmt"
 	"image"
 	"image/color"
-	"image/draw"
+	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"io"
 	"os"
 )
 
-// SubsamplingPixels 2.2.1 Implement 2:1 subsampling in the horizontal and vertical directions, so that only
-// 1/4-th of the input image pixels are taken into account
-func SubsamplingPixels(src []uint8, width, height int) [][3]int {
-	var offset, y, x, idx int
-	var samplingSize int
-	var pixels [][3]int
-
-	samplingSize = (width/2 + width%2) * (height/2 + height%2)
-	pixels = make([][3]int, samplingSize)
-
-	idx = 0
-	for y = 0; y < height; y += 2 {
-		for x = 0; x < width; x += 2 {
-			offset = (y*width + x) * 4
-			pixels[idx][0], pixels[idx][1], pixels[idx][2] = int(src[offset]), int(src[offset+1]), int(src[offset+2])
-			idx++
-		}
-	}
-	return pixels
-}
-
-// SubsamplingPixelsFromImage 2.2.1 Implement 2:1 subsampling in the horizontal and vertical directions, so that only
-// 1/4-th of the input image pixels are taken into account
-func SubsamplingPixelsFromImage(src image.Image) [][3]int {
-	var offset, y, x, idx int
-	var samplingSize int
-	var pixels [][3]int
-
-	bounds := src.Bounds()
-	width, height := bounds.Max.X, bounds.Max.Y
-	img := image.NewRGBA(bounds)
-	draw.Draw(img, bounds, src, image.Point{}, draw.Src)
-
-	samplingSize = (width/2 + width%2) * (height/2 + height%2)
-	pixels = make([][3]int, samplingSize)
-
-	idx = 0
-	for y = 0; y < height; y += 2 {
-		for x = 0; x < width; x += 2 {
-			offset = (y*width + x) * 4
-			pixels[idx][0], pixels[idx][1], pixels[idx][2] = int(img.Pix[offset]), int(img.Pix[offset+1]), int(img.Pix[offset+2])
-			idx++
-		}
-	}
-	return pixels
-}
-
 func Hex(c [3]int) string {
 	return fmt.Sprintf("#%02x%02x%02x", uint8(c[0]), uint8(c[1]), uint8(c[2]))
 }
@@ -70,19 +29,46 @@ func Color(c [3]int) color.Color {
 	}
 }
 
+// Color64 return the 16-bit color of channels ranging from 0 to 65535
+func Color64(c [3]int) color.Color {
+	return color.RGBA64{
+		R: uint16(c[0]),
+		G: uint16(c[1]),
+		B: uint16(c[2]),
+		A: 0xffff,
+	}
+}
+
 func ReadImage(uri string) (image.Image, error) {
 	res, err := os.Open(uri)
 	if err != nil {
 		return nil, err
 	}
+	defer res.Close()
 
-	img, _, err := image.Decode(res)
-	if err != nil {
-		return nil, err
-	}
+	img, _, err := DecodeImage(res)
+	return img, err
+}
 
-	if err = res.Close(); err != nil {
-		return nil, err
+// DecodeImage decode an image in any registered format from r and return the format name,
+// images exceeding the DefaultLimits are rejected before being decoded
+func DecodeImage(r io.Reader) (image.Image, string, error) {
+	return DecodeImageLimits(context.Background(), r, DefaultLimits())
+}
+
+// DecodeImageContext is DecodeImage whose reads fail with the context error once ctx is done
+func DecodeImageContext(ctx context.Context, r io.Reader) (image.Image, string, error) {
+	return DecodeImageLimits(ctx, r, DefaultLimits())
+}
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
 	}
-	return img, nil
+	return cr.r.Read(p)
 }
@@ -1,8 +1,17 @@
 package helper
 
 import (
+	"bytes"
+	"color-thief/sampler"
+	"context"
+	"errors"
 	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
 	"log"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -39,3 +48,304 @@ func TestColor(t *testing.T) {
 		}
 	}
 }
+
+func TestSamplingPixels(t *testing.T) {
+	src := make([]uint8, 5*3*4)
+	for i := 0; i < len(src); i += 4 {
+		src[i], src[i+3] = uint8(i/4), 255
+	}
+	src[3] = 0 // first pixel fully transparent
+
+	if pixels := SamplingPixels(src, 5, 3, Sampling{Step: 2}); len(pixels) != 6 {
+		t.Errorf("expected 6 samples with step 2, got %d", len(pixels))
+	}
+	if pixels := SamplingPixels(src, 5, 3, Sampling{Step: 3}); len(pixels) != 2 || pixels[1][0] != 3 {
+		t.Errorf("unexpected samples with step 3: %v", pixels)
+	}
+	if pixels := SamplingPixels(src, 5, 3, Sampling{Step: 1, AlphaThreshold: 1}); len(pixels) != 14 || pixels[0][0] != 1 {
+		t.Errorf("transparent pixel should be skipped: %v", pixels)
+	}
+	if pixels := SamplingPixels(src, 5, 3, Sampling{Step: 1, Region: image.Rect(1, 1, 9, 9)}); len(pixels) != 8 || pixels[0][0] != 6 || pixels[4][0] != 11 {
+		t.Errorf("unexpected samples within the region: %v", pixels)
+	}
+}
+
+func TestSamplingAlpha(t *testing.T) {
+	// a transparent, a half transparent red and an opaque blue pixel, not premultiplied
+	src := []uint8{255, 255, 255, 0, 255, 0, 0, 128, 0, 0, 255, 255}
+	s := Sampling{Step: 1, AlphaThreshold: 1, Background: color.White}
+
+	pixels := SamplingPixels(src, 3, 1, s)
+	expected := [][3]int{{255, 127, 127}, {0, 0, 255}}
+	if !reflect.DeepEqual(pixels, expected) {
+		t.Errorf("expected %v, got %v", expected, pixels)
+	}
+
+	// the same pixels through an image are premultiplied first
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	copy(img.Pix, src)
+	if pixels = SamplingPixelsFromImage(img, s); !reflect.DeepEqual(pixels, expected) {
+		t.Errorf("expected %v from the image, got %v", expected, pixels)
+	}
+}
+
+func TestDownscale(t *testing.T) {
+	// a black and white checkerboard averages to gray instead of aliasing to either color
+	checker := image.NewGray(image.Rect(0, 0, 8, 6))
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 8; x++ {
+			checker.Pix[checker.PixOffset(x, y)] = uint8(255 * ((x + y) % 2))
+		}
+	}
+	if pixels := SamplingPixelsFromImage(checker, Sampling{Step: 2}); pixels[0] != [3]int{0, 0, 0} {
+		t.Errorf("expected point sampling to alias to black, got %v", pixels[0])
+	}
+	pixels := SamplingPixelsFromImage(checker, Sampling{Step: 1, Downscale: 4})
+	if len(pixels) != 4*3 {
+		t.Fatalf("expected a 4x3 downscaled image, got %d pixels", len(pixels))
+	}
+	for _, p := range pixels {
+		if p != [3]int{128, 128, 128} {
+			t.Fatalf("expected gray, got %v", p)
+		}
+	}
+
+	// straight colors are weighted by alpha: a transparent pixel does not darken its opaque neighbor
+	src := []uint8{0, 0, 0, 0, 200, 100, 50, 255}
+	if pixels = SamplingPixels(src, 2, 1, Sampling{Step: 1, Downscale: 1}); !reflect.DeepEqual(pixels, [][3]int{{200, 100, 50}}) {
+		t.Errorf("unexpected average %v", pixels)
+	}
+}
+
+// opaqueImage hides the concrete type of an image so that it is sampled through At
+type opaqueImage struct {
+	image.Image
+}
+
+func TestSamplingFastPaths(t *testing.T) {
+	ycbcr := img.(*image.YCbCr)
+	bounds := ycbcr.Bounds()
+	rgba := image.NewRGBA(bounds)
+	nrgba := image.NewNRGBA(bounds)
+	gray := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := ycbcr.At(x, y)
+			rgba.Set(x, y, c)
+			gray.Set(x, y, c)
+			r, g, b, _ := c.RGBA()
+			nrgba.SetNRGBA(x, y, color.NRGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(x + y)})
+		}
+	}
+
+	region := image.Rect(13, 7, 301, 222)
+	for _, src := range []image.Image{ycbcr, rgba, nrgba, gray} {
+		for _, s := range []Sampling{DefaultSampling, {Step: 3, Region: region, AlphaThreshold: 40, Background: color.White},
+			{Sampler: sampler.Jittered{Cell: 5, Seed: 1}, Region: region}, {Sampler: sampler.Random{N: 1000}},
+			{Step: 1, Downscale: 64, Region: region, Background: color.Black}} {
+			drawn := image.NewRGBA(bounds)
+			draw.Draw(drawn, bounds, src, bounds.Min, draw.Src)
+			expected := SamplingPixelsFromImage(drawn, s)
+			if pixels := SamplingPixelsFromImage(src, s); !reflect.DeepEqual(pixels, expected) {
+				t.Errorf("%T: fast path differs from draw.Draw with %+v", src, s)
+			}
+			if pixels := SamplingPixelsFromImage(opaqueImage{src}, s); !reflect.DeepEqual(pixels, expected) {
+				t.Errorf("%T: generic path differs from draw.Draw with %+v", src, s)
+			}
+		}
+	}
+}
+
+func TestSamplingDepth(t *testing.T) {
+	bounds := image.Rect(0, 0, 40, 30)
+	nrgba := image.NewNRGBA64(bounds)
+	gray := image.NewGray16(bounds)
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			v := uint16(x*1601 + y*17)
+			nrgba.SetNRGBA64(x, y, color.NRGBA64{R: v, G: ^v, B: uint16(y * 2003), A: uint16(x * 1663)})
+			gray.SetGray16(x, y, color.Gray16{Y: v})
+		}
+	}
+	rgba := image.NewRGBA64(bounds)
+	draw.Draw(rgba, bounds, nrgba, bounds.Min, draw.Src)
+
+	for _, src := range []image.Image{nrgba, rgba, gray} {
+		for _, s := range []Sampling{{Step: 1, Depth: 16}, {Step: 3, Region: image.Rect(5, 3, 37, 29), AlphaThreshold: 40, Background: color.White, Depth: 16},
+			{Step: 1, Downscale: 16, Background: color.Black, Depth: 16}} {
+			if pixels := SamplingPixelsFromImage(src, s); !reflect.DeepEqual(pixels, SamplingPixelsFromImage(opaqueImage{src}, s)) {
+				t.Errorf("%T: fast path differs from At with %+v", src, s)
+			}
+		}
+	}
+
+	// the low byte of the channels is kept
+	pixels := SamplingPixelsFromImage(gray, Sampling{Step: 1, Depth: 16})
+	if pixels[1] != [3]int{1601, 1601, 1601} {
+		t.Errorf("expected 16-bit gray 1601, got %v", pixels[1])
+	}
+	if pixels = SamplingPixelsFromImage(gray, Sampling{Step: 1}); pixels[1] != [3]int{6, 6, 6} {
+		t.Errorf("expected 8-bit gray 6, got %v", pixels[1])
+	}
+
+	// 8-bit pixels are widened exactly
+	src := []uint8{200, 100, 50, 255, 10, 20, 30, 0}
+	if pixels = SamplingPixels(src, 2, 1, Sampling{Step: 1, AlphaThreshold: 1, Depth: 16}); !reflect.DeepEqual(pixels, [][3]int{{200 * 257, 100 * 257, 50 * 257}}) {
+		t.Errorf("unexpected widened pixels %v", pixels)
+	}
+	if r, g, b, a := Color64([3]int{1601, 2, 65535}).RGBA(); r != 1601 || g != 2 || b != 65535 || a != 0xffff {
+		t.Errorf("unexpected 16-bit color %d %d %d %d", r, g, b, a)
+	}
+}
+
+func BenchmarkSubsamplingPixelsAt(b *testing.B) {
+	src := opaqueImage{img}
+	for i := 0; i < b.N; i++ {
+		_ = SubsamplingPixelsFromImage(src)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	l := Limits{MaxPixels: 100, MaxDimension: 20, MaxBytes: 10}
+	for _, size := range [][2]int{{0, 0}, {5, 0}} {
+		if err := l.Check(size[0], size[1]); !errors.Is(err, ErrEmptyImage) {
+			t.Errorf("%v: expected ErrEmptyImage, got %v", size, err)
+		}
+	}
+	for _, size := range [][2]int{{21, 1}, {11, 10}} {
+		if err := l.Check(size[0], size[1]); !errors.Is(err, ErrLimitExceeded) {
+			t.Errorf("%v: expected ErrLimitExceeded, got %v", size, err)
+		}
+	}
+	if err := l.Check(10, 10); err != nil {
+		t.Error(err)
+	}
+	l.MaxTotalPixels = 1000
+	if err := l.CheckFrames(10, 10, 10); err != nil {
+		t.Error(err)
+	}
+	if err := l.CheckFrames(10, 10, 11); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("11 frames: expected ErrLimitExceeded, got %v", err)
+	}
+
+	if _, _, err := DecodeImageLimits(context.Background(), strings.NewReader(strings.Repeat("x", 11)), l); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected ErrLimitExceeded past 10 bytes, got %v", err)
+	}
+
+	defer SetDefaultLimits(DefaultLimits())
+	SetDefaultLimits(Limits{MaxDimension: 100})
+	if _, err := ReadImage("../example/photo1.jpg"); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected ErrLimitExceeded with the default limits, got %v", err)
+	}
+}
+
+func TestCountGIFFrames(t *testing.T) {
+	var buf bytes.Buffer
+
+	// frames with and without a local palette, each preceded by a graphic control extension
+	g := &gif.GIF{Config: image.Config{Width: 30, Height: 20, ColorModel: color.Palette{color.Black, color.White}}}
+	for i := 0; i < 7; i++ {
+		pal := color.Palette{color.Black, color.White}
+		if i%2 == 1 {
+			pal = color.Palette{color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}, color.White}
+		}
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(i, i, 30, 20), pal))
+		g.Delay = append(g.Delay, 10)
+	}
+	g.LoopCount = 3
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	if width, height, frames := countGIFFrames(buf.Bytes()); width != 30 || height != 20 || frames != 7 {
+		t.Errorf("expected 7 frames of 30x20, got %d of %dx%d", frames, width, height)
+	}
+	if _, _, frames := countGIFFrames(buf.Bytes()[:buf.Len()/2]); frames < 1 || frames >= 7 {
+		t.Errorf("expected the frames of the first half, got %d", frames)
+	}
+
+	l := Limits{MaxTotalPixels: 30 * 20 * 6}
+	if _, err := DecodeGIFLimits(context.Background(), bytes.NewReader(buf.Bytes()), l); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected ErrLimitExceeded past 6 frames, got %v", err)
+	}
+	l.MaxTotalPixels *= 2
+	if decoded, err := DecodeGIFLimits(context.Background(), bytes.NewReader(buf.Bytes()), l); err != nil || len(decoded.Image) != 7 {
+		t.Errorf("expected 7 frames, got %v", err)
+	}
+}
+
+func TestSamplingPixelsFormat(t *testing.T) {
+	const width, height = 9, 7
+	s := Sampling{Step: 1, Region: image.Rect(1, 2, 9, 9)}
+
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i] = uint8(i * 37)
+	}
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i], ycbcr.Cr[i] = uint8(60+i*13), uint8(200-i*11)
+	}
+	expected := SamplingPixelsFromImage(ycbcr, s)
+
+	// planes with padded rows
+	i420 := make([]uint8, 12*height+2*8*4)
+	nv12 := make([]uint8, 12*height+12*4)
+	for y := 0; y < height; y++ {
+		copy(i420[y*12:], ycbcr.Y[y*ycbcr.YStride:(y+1)*ycbcr.YStride])
+		copy(nv12[y*12:], ycbcr.Y[y*ycbcr.YStride:(y+1)*ycbcr.YStride])
+	}
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 5; x++ {
+			cb, cr := ycbcr.Cb[y*ycbcr.CStride+x], ycbcr.Cr[y*ycbcr.CStride+x]
+			i420[12*height+y*8+x], i420[12*height+8*4+y*8+x] = cb, cr
+			nv12[12*height+y*12+2*x], nv12[12*height+y*12+2*x+1] = cb, cr
+		}
+	}
+	if pixels := SamplingPixelsFormat(i420, width, height, PixelFormat{Layout: YUV420, Stride: 12, ChromaStride: 8}, s); !reflect.DeepEqual(pixels, expected) {
+		t.Errorf("yuv420: expected %v, got %v", expected, pixels)
+	}
+	if pixels := SamplingPixelsFormat(nv12, width, height, PixelFormat{Layout: NV12, Stride: 12, ChromaStride: 12}, s); !reflect.DeepEqual(pixels, expected) {
+		t.Errorf("nv12: expected %v, got %v", expected, pixels)
+	}
+
+	// the same colors in the other layouts
+	bgra := make([]uint8, 40*height)
+	rgb24 := make([]uint8, width*3*height)
+	rgb565 := make([]uint8, width*2*height)
+	gray := make([]uint8, width*height)
+	var expected565, expectedGray [][3]int
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			r, g, b, _ := ycbcr.At(x, y).RGBA()
+			c := [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)}
+			copy(bgra[y*40+x*4:], []uint8{c[2], c[1], c[0], 255})
+			copy(rgb24[(y*width+x)*3:], c[:])
+			v := uint16(c[0]>>3)<<11 | uint16(c[1]>>2)<<5 | uint16(c[2]>>3)
+			rgb565[(y*width+x)*2], rgb565[(y*width+x)*2+1] = uint8(v), uint8(v>>8)
+			gray[y*width+x] = c[1]
+			if image.Pt(x, y).In(s.Region) {
+				expected565 = append(expected565, [3]int{int(c[0]&^7 | c[0]>>5), int(c[1]&^3 | c[1]>>6), int(c[2]&^7 | c[2]>>5)})
+				expectedGray = append(expectedGray, [3]int{int(c[1]), int(c[1]), int(c[1])})
+			}
+		}
+	}
+	for _, c := range []struct {
+		src      []uint8
+		f        PixelFormat
+		expected [][3]int
+	}{
+		{bgra, PixelFormat{Layout: BGRA, Stride: 40}, expected},
+		{rgb24, PixelFormat{Layout: RGB24}, expected},
+		{rgb565, PixelFormat{Layout: RGB565}, expected565},
+		{gray, PixelFormat{Layout: Gray8}, expectedGray},
+	} {
+		if pixels := SamplingPixelsFormat(c.src, width, height, c.f, s); !reflect.DeepEqual(pixels, c.expected) {
+			t.Errorf("%v: expected %v, got %v", c.f.Layout, c.expected, pixels)
+		}
+		if pixels := SamplingPixelsFormat(c.src[:len(c.src)-1], width, height, c.f, s); len(pixels) != 0 {
+			t.Errorf("%v: short buffer should not be sampled", c.f.Layout)
+		}
+	}
+	if size := (PixelFormat{Layout: BGRA, Stride: 20}).BufferSize(width, height); size != 0 {
+		t.Errorf("stride shorter than a row should be rejected, got size %d", size)
+	}
+}
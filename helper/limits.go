@@ -0,0 +1,179 @@
+package helper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"io"
+	"sync"
+)
+
+var (
+	// ErrLimitExceeded is returned when an image is larger than the decoding limits allow
+	ErrLimitExceeded = errors.New("image exceeds limits")
+	// ErrEmptyImage is returned when the image has no pixel to take into account
+	ErrEmptyImage = errors.New("image has no pixel to sample")
+)
+
+// Limits bound the resources spent decoding an image, a zero field sets no limit
+type Limits struct {
+	MaxPixels      int   // width x height
+	MaxDimension   int   // width or height
+	MaxBytes       int64 // size of the encoded image
+	MaxTotalPixels int64 // width x height x frames of an animation, each frame is composed over the whole screen
+}
+
+var (
+	limitsMu      sync.RWMutex
+	defaultLimits = Limits{MaxPixels: 100000000, MaxDimension: 1 << 16, MaxTotalPixels: 1000000000}
+)
+
+// DefaultLimits return the limits applied when none are given, by default a 100 megapixel photo
+// is decoded but not a file declaring a 50000x50000 image, nor an animation of a billion pixels in all
+func DefaultLimits() Limits {
+	limitsMu.RLock()
+	defer limitsMu.RUnlock()
+	return defaultLimits
+}
+
+// SetDefaultLimits change the limits applied when none are given
+func SetDefaultLimits(l Limits) {
+	limitsMu.Lock()
+	defer limitsMu.Unlock()
+	defaultLimits = l
+}
+
+// Check return an error when a width x height image is empty or exceeds the limits
+func (l Limits) Check(width, height int) error {
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("%w: %dx%d image", ErrEmptyImage, width, height)
+	}
+	if l.MaxDimension > 0 && (width > l.MaxDimension || height > l.MaxDimension) {
+		return fmt.Errorf("%w: %dx%d image, at most %d pixels per side are allowed", ErrLimitExceeded, width, height, l.MaxDimension)
+	}
+	if l.MaxPixels > 0 && int64(width)*int64(height) > int64(l.MaxPixels) {
+		return fmt.Errorf("%w: %dx%d image, at most %d pixels are allowed", ErrLimitExceeded, width, height, l.MaxPixels)
+	}
+	return nil
+}
+
+// CheckFrames return an error when an animation of frames width x height frames exceeds the limits
+func (l Limits) CheckFrames(width, height, frames int) error {
+	if err := l.Check(width, height); err != nil {
+		return err
+	}
+	if l.MaxTotalPixels > 0 && int64(width)*int64(height)*int64(frames) > l.MaxTotalPixels {
+		return fmt.Errorf("%w: %d frames of %dx%d, at most %d pixels are allowed in all", ErrLimitExceeded, frames, width, height, l.MaxTotalPixels)
+	}
+	return nil
+}
+
+// DecodeImageLimits is DecodeImageContext checking the size declared by the image header
+// against the limits before allocating anything
+func DecodeImageLimits(ctx context.Context, r io.Reader, l Limits) (image.Image, string, error) {
+	var img image.Image
+	var format string
+
+	err := decodeLimits(ctx, r, l, func(r io.Reader) (image.Config, error) {
+		var cfg image.Config
+		var err error
+		cfg, format, err = image.DecodeConfig(r)
+		return cfg, err
+	}, func(r io.Reader) error {
+		var err error
+		img, format, err = image.Decode(r)
+		return err
+	})
+	if err != nil {
+		return nil, format, err
+	}
+	return img, format, nil
+}
+
+// DecodeGIFLimits decode every frame of a GIF, the logical screen declared by its header
+// is checked against the limits first, then the frames counted in the encoded blocks before any is decoded
+func DecodeGIFLimits(ctx context.Context, r io.Reader, l Limits) (*gif.GIF, error) {
+	var g *gif.GIF
+
+	err := decodeLimits(ctx, r, l, gif.DecodeConfig, func(r io.Reader) error {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		width, height, frames := countGIFFrames(data)
+		if err = l.CheckFrames(width, height, frames); err != nil {
+			return err
+		}
+		g, err = gif.DecodeAll(bytes.NewReader(data))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// decodeLimits read the image header with config, check it against the limits
+// and replay it to decode along with the rest of r
+func decodeLimits(ctx context.Context, r io.Reader, l Limits, config func(io.Reader) (image.Config, error), decode func(io.Reader) error) error {
+	var header bytes.Buffer
+	var lr *limitReader
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r = &contextReader{ctx: ctx, r: r}
+	if l.MaxBytes > 0 {
+		lr = &limitReader{r: r, max: l.MaxBytes}
+		r = lr
+	}
+
+	cfg, err := config(io.TeeReader(r, &header))
+	if err == nil {
+		err = l.Check(cfg.Width, cfg.Height)
+	}
+	if err == nil {
+		err = decode(io.MultiReader(&header, r))
+	}
+
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if lr != nil && lr.exceeded() {
+		// decoders do not always pass the reader error through
+		return lr.err()
+	}
+	return err
+}
+
+// limitReader fail once more than max bytes are read
+type limitReader struct {
+	r         io.Reader
+	read, max int64
+}
+
+func (lr *limitReader) Read(p []byte) (int, error) {
+	if lr.exceeded() {
+		return 0, lr.err()
+	}
+	if int64(len(p)) > lr.max-lr.read+1 {
+		p = p[:lr.max-lr.read+1]
+	}
+	n, err := lr.r.Read(p)
+	lr.read += int64(n)
+	if lr.exceeded() {
+		return n, lr.err()
+	}
+	return n, err
+}
+
+func (lr *limitReader) exceeded() bool {
+	return lr.read > lr.max
+}
+
+func (lr *limitReader) err() error {
+	return fmt.Errorf("%w: input larger than %d bytes", ErrLimitExceeded, lr.max)
+}
@@ -0,0 +1,408 @@
+package helper
+
+import (
+	"color-thief/sampler"
+	"image"
+	"image/color"
+)
+
+// Sampling selects the pixels of an image that are taken into account
+type Sampling struct {
+	Step           int             // take every Step-th pixel in the horizontal and vertical directions
+	Sampler        sampler.Sampler // pick the pixels by this strategy instead of Step when not nil
+	Downscale      int             // area-average the image so that its longer side is at most Downscale pixels before sampling, 0 keeps it as is
+	AlphaThreshold uint8           // skip pixels whose alpha is below the threshold
+	Background     color.Color     // composite translucent pixels over this color, nil leaves them untouched
+	Region         image.Rectangle // only sample the pixels within the region, the empty rectangle stands for the whole image
+	Depth          int             // bits per channel of the collected pixels, 8 when 0 or 16 to keep the precision of 16-bit images
+}
+
+// DefaultSampling 2.2.1 Implement 2:1 subsampling in the horizontal and vertical directions
+var DefaultSampling = Sampling{Step: 2}
+
+// SubsamplingPixels 2.2.1 Implement 2:1 subsampling in the horizontal and vertical directions, so that only
+// 1/4-th of the input image pixels are taken into account
+func SubsamplingPixels(src []uint8, width, height int) [][3]int {
+	return SamplingPixels(src, width, height, DefaultSampling)
+}
+
+// SamplingPixels collect the pixels picked by s from the packed RGBA buffer src,
+// the colors are not premultiplied by alpha as in the ImageData of a canvas.
+// See SamplingPixelsFormat for other layouts.
+func SamplingPixels(src []uint8, width, height int, s Sampling) [][3]int {
+	return SamplingPixelsFormat(src, width, height, PixelFormat{Layout: RGBA}, s)
+}
+
+// SubsamplingPixelsFromImage 2.2.1 Implement 2:1 subsampling in the horizontal and vertical directions, so that only
+// 1/4-th of the input image pixels are taken into account
+func SubsamplingPixelsFromImage(src image.Image) [][3]int {
+	return SamplingPixelsFromImage(src, DefaultSampling)
+}
+
+// SamplingPixelsFromImage collect the pixels picked by s from the image,
+// a sub-image or any image whose bounds do not start at the origin is sampled within its bounds.
+// *image.RGBA, *image.NRGBA, *image.YCbCr and *image.Gray are read in place, only the sampled pixels
+// are converted, other images are read through At without any copy.
+// At a Depth of 16 the channels range from 0 to 65535 instead of 255.
+func SamplingPixelsFromImage(src image.Image, s Sampling) [][3]int {
+	var at pixelFunc[uint8]
+
+	bounds := s.Bounds(src)
+	if bounds.Empty() {
+		return [][3]int{}
+	}
+	if s.depth() == 16 {
+		return samplingPixels64(src, bounds, s)
+	}
+
+	switch img := src.(type) {
+	case *image.RGBA:
+		at = rgbaAt(img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y):], img.Stride)
+	case *image.NRGBA:
+		at = nrgbaAt(img, bounds.Min)
+	case *image.YCbCr:
+		at = ycbcrAt(img, bounds.Min)
+	case *image.Gray:
+		at = grayAt(img, bounds.Min)
+	default:
+		at = imageAt(src, bounds.Min)
+	}
+	return collect(at, bounds.Dx(), bounds.Dy(), s, true)
+}
+
+// samplingPixels64 collect 16-bit pixels, *image.RGBA64, *image.NRGBA64 and *image.Gray16 are read in place
+func samplingPixels64(src image.Image, bounds image.Rectangle, s Sampling) [][3]int {
+	var at pixelFunc[uint16]
+
+	switch img := src.(type) {
+	case *image.RGBA:
+		at = widen(rgbaAt(img.Pix[img.PixOffset(bounds.Min.X, bounds.Min.Y):], img.Stride))
+	case *image.RGBA64:
+		at = rgba64At(img, bounds.Min)
+	case *image.NRGBA64:
+		at = nrgba64At(img, bounds.Min)
+	case *image.Gray16:
+		at = gray16At(img, bounds.Min)
+	default:
+		at = image64At(src, bounds.Min)
+	}
+	return collect(at, bounds.Dx(), bounds.Dy(), s, true)
+}
+
+// Transparent report whether every pixel of the image within the bounds is fully transparent,
+// the scan stops at the first visible pixel. An empty image is not transparent.
+func Transparent(src image.Image, bounds image.Rectangle) bool {
+	var x, y int
+
+	bounds = bounds.Intersect(src.Bounds())
+	if bounds.Empty() {
+		return false
+	}
+	switch img := src.(type) {
+	case *image.YCbCr, *image.Gray:
+		return false
+	case *image.RGBA:
+		for y = bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x = bounds.Min.X; x < bounds.Max.X; x++ {
+				if img.Pix[img.PixOffset(x, y)+3] != 0 {
+					return false
+				}
+			}
+		}
+		return true
+	case *image.NRGBA:
+		for y = bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x = bounds.Min.X; x < bounds.Max.X; x++ {
+				if img.Pix[img.PixOffset(x, y)+3] != 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	for y = bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x = bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := src.At(x, y).RGBA(); a != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// CompositeRGBA return a copy of the premultiplied image composited over the background
+// the same way the sampled pixels are
+func CompositeRGBA(src *image.RGBA, background color.Color) *image.RGBA {
+	var i int
+	var bg [3]int
+
+	bg = opaque[uint8](background)
+	dst := image.NewRGBA(src.Rect)
+	copy(dst.Pix, src.Pix)
+	for i = 0; i < len(dst.Pix); i += 4 {
+		dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2] = over(dst.Pix[i], dst.Pix[i+3], bg[0], true),
+			over(dst.Pix[i+1], dst.Pix[i+3], bg[1], true),
+			over(dst.Pix[i+2], dst.Pix[i+3], bg[2], true)
+		dst.Pix[i+3] = 255
+	}
+	return dst
+}
+
+// channel is the type of the color channels, 8 or 16 bits wide
+type channel interface {
+	~uint8 | ~uint16
+}
+
+// collector gather the sampled pixels, skipping and compositing them as set by the sampling
+type collector[T channel] struct {
+	pixels        [][3]int
+	threshold     T
+	composite     bool
+	bg            [3]int
+	premultiplied bool
+}
+
+func newCollector[T channel](s Sampling, width, height int, premultiplied bool) *collector[T] {
+	c := &collector[T]{
+		pixels:        make([][3]int, 0, s.sampler().Size(width, height)),
+		threshold:     T(int(s.AlphaThreshold) * int(^T(0)) / 255),
+		premultiplied: premultiplied,
+	}
+	if s.Background != nil {
+		c.composite, c.bg = true, opaque[T](s.Background)
+	}
+	return c
+}
+
+func (c *collector[T]) add(r, g, b, a T) {
+	if a != ^T(0) {
+		c.addTranslucent(r, g, b, a)
+		return
+	}
+	c.pixels = append(c.pixels, [3]int{int(r), int(g), int(b)})
+}
+
+func (c *collector[T]) addTranslucent(r, g, b, a T) {
+	if a < c.threshold {
+		return
+	}
+	if c.composite {
+		r, g, b = over(r, a, c.bg[0], c.premultiplied), over(g, a, c.bg[1], c.premultiplied), over(b, a, c.bg[2], c.premultiplied)
+	}
+	c.pixels = append(c.pixels, [3]int{int(r), int(g), int(b)})
+}
+
+// pixelFunc return the color of the pixel at x, y relative to the top-left corner of the sampled area
+type pixelFunc[T channel] func(x, y int) (r, g, b, a T)
+
+// collect gather the pixels of the width x height area picked by the sampling,
+// after downscaling the area when the sampling asks for it
+func collect[T channel](at pixelFunc[T], width, height int, s Sampling, premultiplied bool) [][3]int {
+	if w, h := s.downscaled(width, height); w != width || h != height {
+		at, width, height = downscale(at, width, height, w, h, premultiplied), w, h
+	}
+
+	c := newCollector[T](s, width, height, premultiplied)
+	s.sampler().Sample(width, height, func(x, y int) {
+		c.add(at(x, y))
+	})
+	return c.pixels
+}
+
+// collectDepth collect 8-bit pixels at the depth of the sampling
+func collectDepth(at pixelFunc[uint8], width, height int, s Sampling, premultiplied bool) [][3]int {
+	if s.depth() == 16 {
+		return collect(widen(at), width, height, s, premultiplied)
+	}
+	return collect(at, width, height, s, premultiplied)
+}
+
+// widen scale 8-bit channels to 16 bits the same way color.RGBA does
+func widen(at pixelFunc[uint8]) pixelFunc[uint16] {
+	return func(x, y int) (uint16, uint16, uint16, uint16) {
+		r, g, b, a := at(x, y)
+		return uint16(r) * 0x101, uint16(g) * 0x101, uint16(b) * 0x101, uint16(a) * 0x101
+	}
+}
+
+// downscale average the pixels of the width x height area within the boxes covered by each pixel
+// of the w x h result. Straight colors are weighted by their alpha so that transparent pixels do not bleed.
+func downscale[T channel](at pixelFunc[T], width, height, w, h int, premultiplied bool) pixelFunc[T] {
+	var x, y, sx, sy, x0, x1, y0, y1, n, offset int
+	var sum [4]int
+	var r, g, b, a T
+
+	dst := make([]T, w*h*4)
+	for y = 0; y < h; y++ {
+		y0, y1 = y*height/h, (y+1)*height/h
+		for x = 0; x < w; x++ {
+			x0, x1 = x*width/w, (x+1)*width/w
+			sum = [4]int{}
+			for sy = y0; sy < y1; sy++ {
+				for sx = x0; sx < x1; sx++ {
+					r, g, b, a = at(sx, sy)
+					if premultiplied {
+						sum[0], sum[1], sum[2] = sum[0]+int(r), sum[1]+int(g), sum[2]+int(b)
+					} else {
+						sum[0], sum[1], sum[2] = sum[0]+int(r)*int(a), sum[1]+int(g)*int(a), sum[2]+int(b)*int(a)
+					}
+					sum[3] += int(a)
+				}
+			}
+
+			n, offset = (x1-x0)*(y1-y0), (y*w+x)*4
+			if premultiplied {
+				dst[offset], dst[offset+1], dst[offset+2] = T((sum[0]+n/2)/n), T((sum[1]+n/2)/n), T((sum[2]+n/2)/n)
+			} else if sum[3] > 0 {
+				dst[offset], dst[offset+1], dst[offset+2] = T((sum[0]+sum[3]/2)/sum[3]), T((sum[1]+sum[3]/2)/sum[3]), T((sum[2]+sum[3]/2)/sum[3])
+			}
+			dst[offset+3] = T((sum[3] + n/2) / n)
+		}
+	}
+	return rgbaAt(dst, w*4)
+}
+
+// rgbaAt read an RGBA buffer whose rows are stride channels apart
+func rgbaAt[T channel](src []T, stride int) pixelFunc[T] {
+	return func(x, y int) (T, T, T, T) {
+		offset := y*stride + x*4
+		return src[offset], src[offset+1], src[offset+2], src[offset+3]
+	}
+}
+
+// nrgbaAt premultiply the pixels the same way draw.Draw does
+func nrgbaAt(img *image.NRGBA, min image.Point) pixelFunc[uint8] {
+	return func(x, y int) (uint8, uint8, uint8, uint8) {
+		offset := img.PixOffset(min.X+x, min.Y+y)
+		sa := uint32(img.Pix[offset+3]) * 0x101
+		return uint8(uint32(img.Pix[offset]) * sa / 0xff >> 8), uint8(uint32(img.Pix[offset+1]) * sa / 0xff >> 8),
+			uint8(uint32(img.Pix[offset+2]) * sa / 0xff >> 8), uint8(sa >> 8)
+	}
+}
+
+// ycbcrAt convert the pixels of a decoded JPEG to RGB, whatever its chroma subsampling
+func ycbcrAt(img *image.YCbCr, min image.Point) pixelFunc[uint8] {
+	return func(x, y int) (uint8, uint8, uint8, uint8) {
+		x, y = min.X+x, min.Y+y
+		r, g, b := color.YCbCrToRGB(img.Y[img.YOffset(x, y)], img.Cb[img.COffset(x, y)], img.Cr[img.COffset(x, y)])
+		return r, g, b, 255
+	}
+}
+
+// imageAt convert the pixels of any image the same way draw.Draw does
+func imageAt(img image.Image, min image.Point) pixelFunc[uint8] {
+	return func(x, y int) (uint8, uint8, uint8, uint8) {
+		r, g, b, a := img.At(min.X+x, min.Y+y).RGBA()
+		return uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)
+	}
+}
+
+func grayAt(img *image.Gray, min image.Point) pixelFunc[uint8] {
+	return func(x, y int) (uint8, uint8, uint8, uint8) {
+		v := img.Pix[img.PixOffset(min.X+x, min.Y+y)]
+		return v, v, v, 255
+	}
+}
+
+// rgba64At read the big-endian channels of a 16-bit image
+func rgba64At(img *image.RGBA64, min image.Point) pixelFunc[uint16] {
+	return func(x, y int) (uint16, uint16, uint16, uint16) {
+		p := img.Pix[img.PixOffset(min.X+x, min.Y+y):]
+		return uint16(p[0])<<8 | uint16(p[1]), uint16(p[2])<<8 | uint16(p[3]),
+			uint16(p[4])<<8 | uint16(p[5]), uint16(p[6])<<8 | uint16(p[7])
+	}
+}
+
+// nrgba64At premultiply the pixels the same way color.NRGBA64 does
+func nrgba64At(img *image.NRGBA64, min image.Point) pixelFunc[uint16] {
+	return func(x, y int) (uint16, uint16, uint16, uint16) {
+		p := img.Pix[img.PixOffset(min.X+x, min.Y+y):]
+		a := uint32(p[6])<<8 | uint32(p[7])
+		return uint16((uint32(p[0])<<8 | uint32(p[1])) * a / 0xffff), uint16((uint32(p[2])<<8 | uint32(p[3])) * a / 0xffff),
+			uint16((uint32(p[4])<<8 | uint32(p[5])) * a / 0xffff), uint16(a)
+	}
+}
+
+func gray16At(img *image.Gray16, min image.Point) pixelFunc[uint16] {
+	return func(x, y int) (uint16, uint16, uint16, uint16) {
+		p := img.Pix[img.PixOffset(min.X+x, min.Y+y):]
+		v := uint16(p[0])<<8 | uint16(p[1])
+		return v, v, v, 0xffff
+	}
+}
+
+// image64At read the 16-bit premultiplied colors of any image
+func image64At(img image.Image, min image.Point) pixelFunc[uint16] {
+	return func(x, y int) (uint16, uint16, uint16, uint16) {
+		r, g, b, a := img.At(min.X+x, min.Y+y).RGBA()
+		return uint16(r), uint16(g), uint16(b), uint16(a)
+	}
+}
+
+// over composite a channel of alpha a over the opaque background channel bg
+func over[T channel](c, a T, bg int, premultiplied bool) T {
+	max := int(^T(0))
+	if premultiplied {
+		return T(int(c) + (bg*(max-int(a))+max/2)/max)
+	}
+	return T((int(c)*int(a) + bg*(max-int(a)) + max/2) / max)
+}
+
+// opaque return the color channels of c at the width of T, with any transparency dropped
+func opaque[T channel](c color.Color) [3]int {
+	r, g, b, a := c.RGBA()
+	if a == 0 {
+		return [3]int{}
+	}
+	// un-premultiply so that a translucent background keeps its hue
+	r, g, b = r*0xffff/a, g*0xffff/a, b*0xffff/a
+	if ^T(0) == 0xff {
+		return [3]int{int(r >> 8), int(g >> 8), int(b >> 8)}
+	}
+	return [3]int{int(r), int(g), int(b)}
+}
+
+// Bounds return the part of the image to sample
+func (s Sampling) Bounds(img image.Image) image.Rectangle {
+	if s.Region.Empty() {
+		return img.Bounds()
+	}
+	return img.Bounds().Intersect(s.Region)
+}
+
+// sampler return the strategy picking the pixels, Step is a shorthand for sampler.Stride
+func (s Sampling) sampler() sampler.Sampler {
+	if s.Sampler != nil {
+		return s.Sampler
+	}
+	return sampler.Stride(s.Step)
+}
+
+// downscaled return the size of the width x height area once downscaled, the aspect ratio is kept
+func (s Sampling) downscaled(width, height int) (int, int) {
+	longer := width
+	if height > longer {
+		longer = height
+	}
+	if s.Downscale < 1 || longer <= s.Downscale {
+		return width, height
+	}
+
+	width, height = (width*s.Downscale+longer/2)/longer, (height*s.Downscale+longer/2)/longer
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	return width, height
+}
+
+// depth return the bits per channel of the sampled pixels
+func (s Sampling) depth() int {
+	if s.Depth == 16 {
+		return 16
+	}
+	return 8
+}
@@ -1,19 +1,22 @@
 package color_thief
 
 import (
+	"bytes"
 	"color-thief/helper"
-	"color-thief/wsm"
-	"color-thief/wu"
+	"color-thief/quantizer"
+	"context"
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"image/png"
+	"io"
 	"os"
 )
 
 // GetColorFromFile return the base color from the image file
 func GetColorFromFile(imgPath string) (color.Color, error) {
-	colors, err := GetPaletteFromFile(imgPath, 10, 0)
+	colors, err := GetPaletteFromFile(imgPath, 10, quantizer.Wu)
 	if err != nil {
 		return color.RGBA{}, err
 	}
@@ -21,8 +24,8 @@ func GetColorFromFile(imgPath string) (color.Color, error) {
 }
 
 // GetColor return the base color from the image
-func GetColor(img image.Image, numColors, functionType int) (color.Color, error) {
-	colors, err := GetPalette(img, numColors, functionType)
+func GetColor(img image.Image, numColors int, algorithm string) (color.Color, error) {
+	colors, err := GetPalette(img, numColors, algorithm)
 	if err != nil {
 		return color.RGBA{}, err
 	}
@@ -30,52 +33,127 @@ func GetColor(img image.Image, numColors, functionType int) (color.Color, error)
 }
 
 // GetPaletteFromFile return cluster similar colors from the image file
-func GetPaletteFromFile(imgPath string, numColors, functionType int) ([]color.Color, error) {
-	var img image.Image
-	var err error
+func GetPaletteFromFile(imgPath string, numColors int, algorithm string) ([]color.Color, error) {
+	file, err := os.Open(imgPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
 
-	// load image
-	img, err = helper.ReadImage(imgPath)
+	palette, _, err := GetPaletteFromReader(file, WithColors(numColors), WithAlgorithm(algorithm))
 	if err != nil {
 		return nil, err
 	}
+	return palette.Colors(), nil
+}
 
-	return GetPalette(img, numColors, functionType)
+// GetPaletteFromReader decode the image from r and return its palette along with the format name,
+// e.g. "jpeg" or "png"
+func GetPaletteFromReader(r io.Reader, opts ...Option) (Palette, string, error) {
+	return GetPaletteFromReaderContext(context.Background(), r, opts...)
 }
 
-// GetPalette return cluster similar colors by the median cut algorithm
-func GetPalette(img image.Image, numColors, functionType int) ([]color.Color, error) {
-	var palette, pixels [][3]int
-	var colors []color.Color
+// GetPaletteFromReaderContext is GetPaletteFromReader that gives up once ctx is done,
+// reads from r fail with the context error so that decoding stops early as well.
+// Images exceeding the limits set by WithLimits fail with ErrLimitExceeded before being decoded.
+func GetPaletteFromReaderContext(ctx context.Context, r io.Reader, opts ...Option) (Palette, string, error) {
+	o := newOptions(opts)
+	img, format, err := helper.DecodeImageLimits(ctx, r, o.Limits)
+	if err != nil {
+		if ctx.Err() == nil && !errors.Is(err, ErrLimitExceeded) && !errors.Is(err, ErrEmptyImage) {
+			err = &DecodeError{Err: err}
+		}
+		return nil, format, err
+	}
 
-	if numColors < 1 {
-		return nil, errors.New("number of colors should be greater than 0")
+	palette, _, err := extract(ctx, img, &o, false)
+	return palette, format, err
+}
+
+// GetPaletteFromBytes decode the encoded image data and return its palette along with the format name
+func GetPaletteFromBytes(data []byte, opts ...Option) (Palette, string, error) {
+	return GetPaletteFromReaderContext(context.Background(), bytes.NewReader(data), opts...)
+}
+
+// GetPaletteFromBytesContext is GetPaletteFromBytes that gives up once ctx is done
+func GetPaletteFromBytesContext(ctx context.Context, data []byte, opts ...Option) (Palette, string, error) {
+	return GetPaletteFromReaderContext(ctx, bytes.NewReader(data), opts...)
+}
+
+// GetPalette return cluster similar colors by the quantizer registered under algorithm,
+// see quantizer.Names for the available ones
+func GetPalette(img image.Image, numColors int, algorithm string) ([]color.Color, error) {
+	palette, err := GetPaletteWithOptions(img, WithColors(numColors), WithAlgorithm(algorithm))
+	if err != nil {
+		return nil, err
 	}
+	return palette.Colors(), nil
+}
 
-	pixels = helper.SubsamplingPixelsFromImage(img)
-	switch functionType {
-	case 0:
-		palette = wu.QuantWu(pixels, numColors)
-		break
-	case 1:
-		palette = wsm.WSM(pixels, numColors)
-		break
-	default:
-		return nil, errors.New("function type should be either 0 or 1")
+// GetPaletteWithOptions return cluster similar colors from the image along with their share of it,
+// the defaults yield 10 colors by the wu quantizer on a 2:1 subsampled image
+func GetPaletteWithOptions(img image.Image, opts ...Option) (Palette, error) {
+	return GetPaletteContext(context.Background(), img, opts...)
+}
+
+// GetPaletteContext is GetPaletteWithOptions that gives up with the context error once ctx is done,
+// the context is checked between the sampling and quantization stages and by the quantizer itself
+func GetPaletteContext(ctx context.Context, img image.Image, opts ...Option) (Palette, error) {
+	o := newOptions(opts)
+	palette, _, err := extract(ctx, img, &o, false)
+	return palette, err
+}
+
+// extract sample the image and quantize the pixels, when index is set it also
+// return the function mapping any color to its swatch
+func extract(ctx context.Context, img image.Image, o *Options, index bool) (Palette, quantizer.IndexFunc, error) {
+	var pixels [][3]int
+
+	q, err := o.quantizer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return nil, nil, err
 	}
+	// transparent pixels would otherwise count as black
+	if o.Background == nil && helper.Transparent(img, o.sampling().Bounds(img)) {
+		return nil, nil, fmt.Errorf("%w: every pixel is transparent", ErrEmptyImage)
+	}
+	pixels = helper.SamplingPixelsFromImage(img, o.sampling())
+	if len(pixels) == 0 {
+		return nil, nil, ErrEmptyImage
+	}
+	return quantize(ctx, q, pixels, o, index)
+}
+
+// quantize reduce the sampled pixels to a palette, when index is set it also
+// return the function mapping any color to its swatch
+func quantize(ctx context.Context, q quantizer.Quantizer, pixels [][3]int, o *Options, index bool) (Palette, quantizer.IndexFunc, error) {
+	var clusters []quantizer.Cluster
+	var indexFunc quantizer.IndexFunc
+	var err error
 
-	colors = make([]color.Color, len(palette))
-	for i, v := range palette {
-		colors[i] = helper.Color(v)
+	if err = ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if index {
+		clusters, indexFunc, err = quantizer.QuantizeIndex(ctx, q, pixels, o.NumColors, o.config())
+	} else {
+		clusters, err = q.Quantize(ctx, pixels, o.NumColors, o.config())
+	}
+	if err != nil {
+		return nil, nil, err
 	}
-	return colors, nil
+	return newPalette(clusters, len(pixels), o.Depth), indexFunc, nil
 }
 
 func PrintColor(colors []color.Color, filename string) error {
 	imgWidth := 100 * len(colors)
 	imgHeight := 200
 	if imgWidth == 0 {
-		return errors.New("colors empty")
+		return ErrEmptyPalette
 	}
 
 	palettes := image.NewPaletted(image.Rect(0, 0, imgWidth, imgHeight), colors)
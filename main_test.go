@@ -0,0 +1,393 @@
+package color_thief
+
+import (
+	"bytes"
+	"color-thief/colorspace"
+	"color-thief/helper"
+	"color-thief/quantizer"
+	"color-thief/sampler"
+	"color-thief/wu"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var img image.Image
+
+func init() {
+	var err error
+	img, err = helper.ReadImage("example/photo1.jpg")
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func TestGetPaletteFromBytes(t *testing.T) {
+	data, err := os.ReadFile("example/baboon.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	palette, format, err := GetPaletteFromBytes(data, WithColors(4), WithAlgorithm(quantizer.WSM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" {
+		t.Errorf("expected png format, got %s", format)
+	}
+
+	colors, err := GetPaletteFromFile("example/baboon.png", 4, quantizer.WSM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(palette.Colors(), colors) {
+		t.Errorf("palette from bytes %v differs from palette from file %v", palette.Colors(), colors)
+	}
+
+	if _, _, err = GetPaletteFromBytes(data[:len(data)/2]); err == nil {
+		t.Error("expected an error for truncated data")
+	}
+}
+
+func TestGetPaletteContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := GetPaletteContext(ctx, img, WithAlgorithm(quantizer.WSM)); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	file, err := os.Open("example/photo2.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, _, err = GetPaletteFromReaderContext(ctx, file); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled while decoding, got %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := GetPalette(img, 0, quantizer.Wu); !errors.Is(err, ErrInvalidColorCount) {
+		t.Errorf("expected ErrInvalidColorCount, got %v", err)
+	}
+	if _, err := GetPalette(img, 6, "median-cut"); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("expected ErrUnknownAlgorithm, got %v", err)
+	}
+	if _, err := GetPaletteWithOptions(img, WithHistogramBits(9)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("expected ErrInvalidOption, got %v", err)
+	}
+	if _, err := GetPaletteWithOptions(img, WithHistogramBits(8)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("expected ErrInvalidOption for 8 bits with wu, got %v", err)
+	}
+	if _, err := GetPaletteWithOptions(img, WithHistogramBits(8), WithAlgorithm(quantizer.WSM)); err != nil {
+		t.Errorf("unexpected error for 8 bits with wsm: %v", err)
+	}
+	if _, err := GetPaletteWithOptions(image.NewRGBA(image.Rect(0, 0, 0, 0))); !errors.Is(err, ErrEmptyImage) {
+		t.Errorf("expected ErrEmptyImage, got %v", err)
+	}
+	if err := PrintColor(nil, "empty.png"); !errors.Is(err, ErrEmptyPalette) {
+		t.Errorf("expected ErrEmptyPalette, got %v", err)
+	}
+
+	var decodeErr *DecodeError
+	_, _, err := GetPaletteFromBytes([]byte("not an image"))
+	if !errors.Is(err, ErrDecode) || !errors.As(err, &decodeErr) || !errors.Is(err, image.ErrFormat) {
+		t.Errorf("expected a DecodeError wrapping image.ErrFormat, got %v", err)
+	}
+}
+
+func TestTransparency(t *testing.T) {
+	// a logo: a red disc on a transparent canvas stored as transparent white
+	logo := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.NRGBA{R: 255, G: 255, B: 255}
+			if (x-20)*(x-20)+(y-20)*(y-20) < 100 {
+				c = color.NRGBA{R: 200, A: 255}
+			}
+			logo.SetNRGBA(x, y, c)
+		}
+	}
+
+	palette, err := GetPaletteWithOptions(logo, WithColors(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if palette[0].Hex() != "#000000" {
+		t.Errorf("transparent pixels should count as black by default, got %v", palette)
+	}
+
+	palette, err = GetPaletteWithOptions(logo, WithColors(2), WithAlphaThreshold(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(palette) != 1 || palette[0].Hex() != "#c80000" {
+		t.Errorf("only the red disc should remain, got %v", palette)
+	}
+
+	palette, err = GetPaletteWithOptions(logo, WithColors(2), WithBackground(color.White))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if palette[0].Hex() != "#ffffff" {
+		t.Errorf("transparent pixels should count as the white background, got %v", palette)
+	}
+
+	if _, err = GetPaletteWithOptions(image.NewNRGBA(image.Rect(0, 0, 4, 4)), WithAlphaThreshold(1)); !errors.Is(err, ErrEmptyImage) {
+		t.Errorf("expected ErrEmptyImage for a fully transparent image, got %v", err)
+	}
+}
+
+func TestRegion(t *testing.T) {
+	// left half red, right half blue
+	src := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 32 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+
+	sub := src.SubImage(image.Rect(33, 5, 64, 30))
+	palette, err := GetPaletteWithOptions(sub, WithColors(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(palette) != 1 || palette[0].Hex() != "#0000ff" || palette[0].Population != 16*13 {
+		t.Errorf("only the blue half should be sampled, got %v", palette)
+	}
+
+	dst, palette, err := Quantize(src, 2, WithRegion(image.Rect(0, 0, 31, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst.Bounds() != image.Rect(0, 0, 31, 32) || len(palette) != 1 || palette[0].Hex() != "#ff0000" {
+		t.Errorf("only the red half should be quantized, got %v %v", dst.Bounds(), palette)
+	}
+}
+
+func TestSampler(t *testing.T) {
+	expected, err := GetPaletteWithOptions(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if palette, _ := GetPaletteWithOptions(img, WithSampler(sampler.Stride(2))); !reflect.DeepEqual(palette, expected) {
+		t.Errorf("stride 2 sampler should match the defaults, got %v", palette)
+	}
+	if palette, _ := GetPaletteWithOptions(img, WithSampler(sampler.Full{}), WithStride(2)); !reflect.DeepEqual(palette, expected) {
+		t.Errorf("WithStride should replace the sampler, got %v", palette)
+	}
+
+	palette, err := GetPaletteWithOptions(img, WithSampler(sampler.Budget(10000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, s := range palette {
+		total += s.Population
+	}
+	if total > 10000 {
+		t.Errorf("expected at most 10000 samples, got %d", total)
+	}
+
+	if _, err = GetPaletteWithOptions(img, WithSampler(sampler.Random{N: -1})); !errors.Is(err, ErrEmptyImage) {
+		t.Errorf("expected ErrEmptyImage without any sample, got %v", err)
+	}
+}
+
+func TestDownscale(t *testing.T) {
+	palette, err := GetPaletteWithOptions(img, WithDownscale(100), WithSampler(sampler.Full{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, s := range palette {
+		total += s.Population
+	}
+	// example/photo1.jpg is 4:3
+	if total != 100*75 {
+		t.Errorf("expected a 100x75 downscaled image, got %d samples", total)
+	}
+	if _, err = GetPaletteWithOptions(img, WithDownscale(-1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("expected ErrInvalidOption for a negative size, got %v", err)
+	}
+}
+
+func TestDepth(t *testing.T) {
+	// two shades that only differ below 8 bits, next to a distinct color
+	src := image.NewRGBA64(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			switch {
+			case y >= 8:
+				src.SetRGBA64(x, y, color.RGBA64{R: 0xe000, G: 0x1000, B: 0x2000, A: 0xffff})
+			case x%2 == 0:
+				src.SetRGBA64(x, y, color.RGBA64{R: 0x1234, G: 0x5678, B: 0x9abc, A: 0xffff})
+			default:
+				src.SetRGBA64(x, y, color.RGBA64{R: 0x1250, G: 0x5690, B: 0x9ad0, A: 0xffff})
+			}
+		}
+	}
+
+	// wu averages the shades, wsm keeps the last pixel of each histogram cell
+	for algorithm, shade := range map[string]color.Color{
+		quantizer.Wu:  color.RGBA64{R: 0x1242, G: 0x5684, B: 0x9ac6, A: 0xffff},
+		quantizer.WSM: color.RGBA64{R: 0x1250, G: 0x5690, B: 0x9ad0, A: 0xffff},
+	} {
+		palette, err := GetPaletteWithOptions(src, WithColors(2), WithAlgorithm(algorithm), WithStride(1), WithDepth(16))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []color.Color{shade, color.RGBA64{R: 0xe000, G: 0x1000, B: 0x2000, A: 0xffff}}
+		if colors := palette.Colors(); !reflect.DeepEqual(colors, expected) && !reflect.DeepEqual(colors, []color.Color{expected[1], expected[0]}) {
+			t.Errorf("%s: expected the 16-bit colors %v, got %v", algorithm, expected, colors)
+		}
+	}
+
+	dst, palette, err := Quantize(src, 2, WithDepth(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst.ColorIndexAt(0, 0) == dst.ColorIndexAt(0, 15) || palette[dst.ColorIndexAt(0, 15)].Hex() != "#e01020" {
+		t.Errorf("unexpected remapping %v", dst.Pix)
+	}
+
+	if _, err = GetPaletteWithOptions(src, WithDepth(12)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("expected ErrInvalidOption for a 12-bit depth, got %v", err)
+	}
+}
+
+func TestColorSpace(t *testing.T) {
+	checker := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			checker.Set(x, y, []color.Color{color.RGBA{R: 255, A: 255}, color.RGBA{G: 255, A: 255}}[(x+y)%2])
+		}
+	}
+	for _, algorithm := range []string{quantizer.Wu, quantizer.WSM} {
+		palette, err := GetPaletteWithOptions(checker, WithColors(1), WithAlgorithm(algorithm), WithStride(1), WithColorSpace(colorspace.LinearRGB))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if palette[0].Hex() != "#bcbc00" {
+			t.Errorf("%s: expected the linear light mix #bcbc00, got %s", algorithm, palette[0].Hex())
+		}
+	}
+
+	// clustering in CIELAB reduces the perceived error
+	pixels := helper.SubsamplingPixelsFromImage(img)
+	meanDeltaE := func(palette Palette) float64 {
+		colors := make([][3]int, len(palette))
+		for i, s := range palette {
+			r, g, b, _ := s.Color.RGBA()
+			colors[i] = [3]int{int(r >> 8), int(g >> 8), int(b >> 8)}
+		}
+		return colorspace.MeanDeltaE(pixels, colors)
+	}
+	for _, algorithm := range []string{quantizer.Wu, quantizer.WSM} {
+		for _, k := range []int{6, 16} {
+			rgb, err := GetPaletteWithOptions(img, WithAlgorithm(algorithm), WithColors(k))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, space := range []colorspace.Space{colorspace.Lab, colorspace.OKLab, colorspace.YCbCr} {
+				palette, err := GetPaletteWithOptions(img, WithAlgorithm(algorithm), WithColors(k), WithColorSpace(space))
+				if err != nil {
+					t.Fatalf("%s: unexpected error in %v: %v", algorithm, space, err)
+				}
+				t.Logf("%s, %d colors in %v: mean ΔE %.2f, %.2f in rgb", algorithm, k, space, meanDeltaE(palette), meanDeltaE(rgb))
+				if space == colorspace.Lab && meanDeltaE(palette) >= meanDeltaE(rgb) {
+					t.Errorf("%s, %d colors: expected a lower mean ΔE in lab than in rgb", algorithm, k)
+				}
+			}
+		}
+	}
+	if _, err := GetPaletteWithOptions(img, WithChannelWeights(wu.Rec601)); err != nil {
+		t.Errorf("unexpected error with luma weights: %v", err)
+	}
+	if _, err := GetPaletteWithOptions(img, WithChannelWeights([3]float64{1, -1, 1})); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("expected ErrInvalidOption for a negative weight, got %v", err)
+	}
+	if _, err := GetPaletteWithOptions(img, WithColorSpace(colorspace.Space(-1))); !errors.Is(err, ErrUnsupportedColorSpace) {
+		t.Errorf("expected ErrUnsupportedColorSpace, got %v", err)
+	}
+}
+
+func TestManyColors(t *testing.T) {
+	palette, err := GetPaletteWithOptions(img, WithColors(1024), WithStride(1), WithHistogramBits(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(palette) != 1024 {
+		t.Errorf("expected 1024 colors, got %d", len(palette))
+	}
+	if palette, err = GetPaletteWithOptions(img, WithColors(1<<22)); err != nil || len(palette) >= 1<<15 {
+		t.Errorf("expected the palette to be capped to the non-empty cells of 5 bits, got %d colors, %v", len(palette), err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 2000, 1000))); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	if _, _, err := GetPaletteFromBytes(data); err != nil {
+		t.Errorf("default limits should let a 2000x1000 image through, got %v", err)
+	}
+	for _, l := range []helper.Limits{{MaxPixels: 1000000}, {MaxDimension: 1500}, {MaxBytes: int64(len(data)) - 1}} {
+		var decodeErr *DecodeError
+		if _, _, err := GetPaletteFromBytes(data, WithLimits(l)); !errors.Is(err, ErrLimitExceeded) || errors.As(err, &decodeErr) {
+			t.Errorf("%+v: expected ErrLimitExceeded, got %v", l, err)
+		}
+	}
+
+	// a tiny file declaring a 50000x50000 image
+	bomb := make([]byte, len(data))
+	copy(bomb, data)
+	binary.BigEndian.PutUint32(bomb[16:], 50000)
+	binary.BigEndian.PutUint32(bomb[20:], 50000)
+	binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+	if _, _, err := GetPaletteFromBytes(bomb); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected ErrLimitExceeded, got %v", err)
+	}
+
+	transparent := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	if _, err := GetPaletteWithOptions(transparent); !errors.Is(err, ErrEmptyImage) {
+		t.Errorf("expected ErrEmptyImage for a fully transparent image, got %v", err)
+	}
+	if palette, err := GetPaletteWithOptions(transparent, WithBackground(color.White)); err != nil || palette[0].Hex() != "#ffffff" {
+		t.Errorf("expected the background, got %v %v", palette, err)
+	}
+}
+
+func TestFormats(t *testing.T) {
+	for file, format := range map[string]string{
+		"fixture.png": "png", "fixture.ppm": "ppm", "fixture.pam": "pam", "fixture.ff": "farbfeld",
+		"fixture.qoi": "qoi", "fixture.bmp": "bmp", "fixture-alpha.bmp": "bmp",
+	} {
+		data, err := os.ReadFile("example/" + file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		palette, name, err := GetPaletteFromBytes(data, WithColors(4))
+		if err != nil || name != format || len(palette) == 0 {
+			t.Errorf("%s: expected a %s palette, got %s %v %v", file, format, name, palette, err)
+		}
+	}
+	if _, err := GetPaletteFromFile("example/fixture.qoi", 4, quantizer.Wu); err != nil {
+		t.Error(err)
+	}
+}
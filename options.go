@@ -0,0 +1,282 @@
+package color_thief
+
+import (
+	"color-thief/colorspace"
+	"color-thief/dither"
+	"color-thief/helper"
+	"color-thief/quantizer"
+	"color-thief/sampler"
+	"color-thief/wsm"
+	"color-thief/wu"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Options configures a palette extraction, see the With* functions for the meaning of each field
+type Options struct {
+	NumColors      int
+	Algorithm      string
+	Stride         int
+	Sampler        sampler.Sampler
+	Downscale      int
+	MaxIterations  int
+	Tolerance      float64
+	HistBits       int
+	ColorSpace     colorspace.Space
+	AlphaThreshold uint8
+	Background     color.Color
+	Region         image.Rectangle
+	Dither         dither.Options
+	Limits         helper.Limits
+	FramePalettes  bool
+	Depth          int
+	Weights        [3]float64
+
+	alphaSet bool // WithAlphaThreshold was given, 0 included
+}
+
+// Option modifies the Options of a palette extraction
+type Option func(*Options)
+
+// defaultOptions reproduce GetPaletteFromFile: 10 colors by wu on a 2:1 subsampled image
+func defaultOptions() Options {
+	return Options{
+		NumColors:     10,
+		Algorithm:     quantizer.Wu,
+		Stride:        helper.DefaultSampling.Step,
+		MaxIterations: wsm.MaxIterations,
+		Tolerance:     wsm.Tolerance,
+		HistBits:      wsm.HistBits,
+		ColorSpace:    colorspace.RGB,
+		Dither:        dither.Options{Method: dither.None, Strength: 1},
+		Limits:        helper.DefaultLimits(),
+		Depth:         8,
+	}
+}
+
+// newOptions apply the options over the defaults
+func newOptions(opts []Option) Options {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithColors set the number of colors of the palette
+func WithColors(n int) Option {
+	return func(o *Options) {
+		o.NumColors = n
+	}
+}
+
+// WithAlgorithm set the name of the registered quantizer used to build the palette
+func WithAlgorithm(name string) Option {
+	return func(o *Options) {
+		o.Algorithm = name
+	}
+}
+
+// WithStride only sample every n-th pixel in the horizontal and vertical directions,
+// 1 takes every pixel into account. It is a shorthand for WithSampler(sampler.Stride(n)).
+func WithStride(n int) Option {
+	return func(o *Options) {
+		o.Stride = n
+		o.Sampler = nil
+	}
+}
+
+// WithSampler pick the sampled pixels by s, e.g. sampler.Budget(100000) bounds the cost of huge images
+// while small images keep every pixel, see the sampler package for the available strategies
+func WithSampler(s sampler.Sampler) Option {
+	return func(o *Options) {
+		o.Sampler = s
+	}
+}
+
+// WithDownscale area-average the image so that its longer side is at most n pixels before sampling it,
+// unlike a stride it keeps fine textures, halftones and dithering patterns from aliasing into colors
+// that are barely in the image. 0 keeps the image as is.
+func WithDownscale(n int) Option {
+	return func(o *Options) {
+		o.Downscale = n
+	}
+}
+
+// WithMaxIterations set the iteration cap of iterative quantizers such as wsm
+func WithMaxIterations(n int) Option {
+	return func(o *Options) {
+		o.MaxIterations = n
+	}
+}
+
+// WithTolerance set the loss improvement below which an iterative quantizer is considered converged
+func WithTolerance(t float64) Option {
+	return func(o *Options) {
+		o.Tolerance = t
+	}
+}
+
+// WithHistogramBits set the histogram precision per channel, from 5 to 7 bits for wu and from 4 to 8 for wsm.
+// Finer histograms keep subtle gradients such as skin tones and skies apart at the cost of memory:
+// wu needs about 86MB at 7 bits and wsm 64MB at 8 bits.
+func WithHistogramBits(bits int) Option {
+	return func(o *Options) {
+		o.HistBits = bits
+	}
+}
+
+// WithColorSpace set the color space in which the quantizer clusters the pixels
+func WithColorSpace(space colorspace.Space) Option {
+	return func(o *Options) {
+		o.ColorSpace = space
+	}
+}
+
+// WithAlphaThreshold skip pixels whose alpha is below the threshold, 0 keeps every pixel
+// and 1 only skips the fully transparent ones
+func WithAlphaThreshold(a uint8) Option {
+	return func(o *Options) {
+		o.AlphaThreshold = a
+		o.alphaSet = true
+	}
+}
+
+// WithBackground composite translucent pixels over the background color before quantizing them,
+// otherwise they count as composited over black
+func WithBackground(c color.Color) Option {
+	return func(o *Options) {
+		o.Background = c
+	}
+}
+
+// WithRegion only take the pixels within r into account, r is in the coordinates of the image
+// and Quantize returns a paletted image of that region only. Passing a SubImage works as well.
+func WithRegion(r image.Rectangle) Option {
+	return func(o *Options) {
+		o.Region = r
+	}
+}
+
+// WithDither diffuse the quantization error with the method when Quantize remaps the image,
+// strength from 0 to 1 scales the diffused error
+func WithDither(method dither.Method, strength float64) Option {
+	return func(o *Options) {
+		o.Dither.Method = method
+		o.Dither.Strength = strength
+	}
+}
+
+// WithSerpentine scan every other row from right to left when diffusing the quantization error
+func WithSerpentine() Option {
+	return func(o *Options) {
+		o.Dither.Serpentine = true
+	}
+}
+
+// WithLimits bound the size of the images decoded by GetPaletteFromReader and GetPaletteFromBytes,
+// helper.SetDefaultLimits changes the limits of every call
+func WithLimits(l helper.Limits) Option {
+	return func(o *Options) {
+		o.Limits = l
+	}
+}
+
+// WithFramePalettes also build the palette of each frame in GetPaletteFromGIF
+func WithFramePalettes() Option {
+	return func(o *Options) {
+		o.FramePalettes = true
+	}
+}
+
+// WithDepth keep bits of precision per channel from sampling to the palette, 8 or 16.
+// At 16 the quantizer works on the full precision of 16-bit images and the swatches are color.RGBA64,
+// 8-bit images are widened so that their colors are exact as well.
+func WithDepth(bits int) Option {
+	return func(o *Options) {
+		o.Depth = bits
+	}
+}
+
+// WithChannelWeights weigh the squared error along each channel when wu splits its boxes, e.g. wu.Rec709
+// favors splits along green, to which the eye is the most sensitive. Only the ratios between the weights matter.
+func WithChannelWeights(w [3]float64) Option {
+	return func(o *Options) {
+		o.Weights = w
+	}
+}
+
+func (o *Options) validate() error {
+	if o.Stride < 1 {
+		return fmt.Errorf("%w: stride should be greater than 0, got %d", ErrInvalidOption, o.Stride)
+	}
+	if o.Downscale < 0 {
+		return fmt.Errorf("%w: downscale size should not be negative, got %d", ErrInvalidOption, o.Downscale)
+	}
+	if o.MaxIterations < 0 {
+		return fmt.Errorf("%w: iteration limit should not be negative, got %d", ErrInvalidOption, o.MaxIterations)
+	}
+	if o.Tolerance < 0 {
+		return fmt.Errorf("%w: tolerance should not be negative, got %v", ErrInvalidOption, o.Tolerance)
+	}
+	minBits, maxBits := wsm.MinHistBits, wsm.MaxHistBits
+	if o.Algorithm == quantizer.Wu {
+		minBits, maxBits = wu.MinHistBits, wu.MaxHistBits
+	}
+	if o.HistBits < minBits || o.HistBits > maxBits {
+		return fmt.Errorf("%w: histogram bits should be between %d and %d, got %d", ErrInvalidOption, minBits, maxBits, o.HistBits)
+	}
+	if o.Depth != 8 && o.Depth != 16 {
+		return fmt.Errorf("%w: depth should be 8 or 16 bits, got %d", ErrInvalidOption, o.Depth)
+	}
+	if o.Weights[0] < 0 || o.Weights[1] < 0 || o.Weights[2] < 0 {
+		return fmt.Errorf("%w: channel weights should not be negative, got %v", ErrInvalidOption, o.Weights)
+	}
+	if o.Dither.Method < dither.None || o.Dither.Method > dither.Riemersma {
+		return fmt.Errorf("%w: unknown dithering method %v", ErrInvalidOption, o.Dither.Method)
+	}
+	if o.Dither.Strength < 0 || o.Dither.Strength > 1 {
+		return fmt.Errorf("%w: dithering strength should be between 0 and 1, got %v", ErrInvalidOption, o.Dither.Strength)
+	}
+	return nil
+}
+
+// quantizer validate the options and return the quantizer they select
+func (o *Options) quantizer() (quantizer.Quantizer, error) {
+	if o.NumColors < 1 {
+		return nil, fmt.Errorf("%w: should be greater than 0, got %d", ErrInvalidColorCount, o.NumColors)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+
+	q, ok := quantizer.Lookup(o.Algorithm)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownAlgorithm, o.Algorithm)
+	}
+	return q, nil
+}
+
+func (o *Options) sampling() helper.Sampling {
+	return helper.Sampling{
+		Step:           o.Stride,
+		Sampler:        o.Sampler,
+		Downscale:      o.Downscale,
+		AlphaThreshold: o.AlphaThreshold,
+		Background:     o.Background,
+		Region:         o.Region,
+		Depth:          o.Depth,
+	}
+}
+
+func (o *Options) config() quantizer.Config {
+	return quantizer.Config{
+		MaxIterations: o.MaxIterations,
+		Tolerance:     o.Tolerance,
+		HistBits:      o.HistBits,
+		ColorSpace:    o.ColorSpace,
+		Depth:         o.Depth,
+		Weights:       o.Weights,
+	}
+}
@@ -0,0 +1,83 @@
+package color_thief
+
+import (
+	"color-thief/helper"
+	"color-thief/quantizer"
+	"fmt"
+	"image/color"
+)
+
+// Swatch is a color of the palette along with its weight in the image.
+// The statistics are computed over the sampled pixels, which stand for the whole image.
+// The Variance is measured the way the quantizer clustered the pixels: in sRGB channel units by default,
+// 16-bit ones at a depth of 16, along the axes of the space scaled to the channel range with WithColorSpace,
+// and weighted per channel by wu with WithChannelWeights. Variances compare between palettes of the same options only.
+type Swatch struct {
+	Color      color.Color
+	Population int     // number of sampled pixels mapped to the color
+	Share      float64 // fraction of the sampled pixels mapped to the color, from 0 to 1
+	Variance   float64 // mean squared distance of these pixels to the color, see above for its unit
+}
+
+// Hex return the color in the #rrggbb notation
+func (s Swatch) Hex() string {
+	r, g, b, _ := s.Color.RGBA()
+	return helper.Hex([3]int{int(r >> 8), int(g >> 8), int(b >> 8)})
+}
+
+// String return the color along with its share of the image, e.g. "#1b2a4e 42%"
+func (s Swatch) String() string {
+	return fmt.Sprintf("%s %.0f%%", s.Hex(), s.Share*100)
+}
+
+// Palette is a list of swatches sorted from the most to the least dominant color
+type Palette []Swatch
+
+// Colors return the colors of the palette
+func (p Palette) Colors() []color.Color {
+	colors := make([]color.Color, len(p))
+	for i, s := range p {
+		colors[i] = s.Color
+	}
+	return colors
+}
+
+// colors return the palette as RGB triplets
+func (p Palette) colors() [][3]int {
+	colors := make([][3]int, len(p))
+	for i, s := range p {
+		r, g, b, _ := s.Color.RGBA()
+		colors[i] = [3]int{int(r >> 8), int(g >> 8), int(b >> 8)}
+	}
+	return colors
+}
+
+// Filter return the swatches covering at least minShare of the image
+func (p Palette) Filter(minShare float64) Palette {
+	filtered := make(Palette, 0, len(p))
+	for _, s := range p {
+		if s.Share >= minShare {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+// newPalette build the swatches of the clusters, whose channels are depth bits wide
+func newPalette(clusters []quantizer.Cluster, total, depth int) Palette {
+	palette := make(Palette, len(clusters))
+	for i, c := range clusters {
+		palette[i] = Swatch{
+			Color:      helper.Color(c.Color),
+			Population: c.Count,
+			Variance:   c.Variance,
+		}
+		if depth == 16 {
+			palette[i].Color = helper.Color64(c.Color)
+		}
+		if total > 0 {
+			palette[i].Share = float64(c.Count) / float64(total)
+		}
+	}
+	return palette
+}
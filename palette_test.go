@@ -0,0 +1,53 @@
+package color_thief
+
+import (
+	"color-thief/quantizer"
+	"math"
+	"testing"
+)
+
+func TestGetPaletteWithOptions(t *testing.T) {
+	for _, name := range []string{quantizer.Wu, quantizer.WSM} {
+		palette, err := GetPaletteWithOptions(img, WithColors(6), WithAlgorithm(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(palette) != 6 {
+			t.Fatalf("%s: expected 6 swatches, got %d", name, len(palette))
+		}
+
+		share := 0.0
+		for i, s := range palette {
+			if i > 0 && s.Population > palette[i-1].Population {
+				t.Errorf("%s: swatches are not sorted by population: %v", name, palette)
+			}
+			share += s.Share
+		}
+		if math.Abs(share-1) > 1e-9 {
+			t.Errorf("%s: shares should add up to 1, got %v", name, share)
+		}
+	}
+}
+
+func TestPalette(t *testing.T) {
+	palette, err := GetPaletteWithOptions(img, WithColors(6))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex := palette[0].Hex(); hex != "#6ccee1" {
+		t.Errorf("unexpected hex %s", hex)
+	}
+	if s := palette[0].String(); s != "#6ccee1 29%" {
+		t.Errorf("unexpected swatch %s", s)
+	}
+
+	filtered := palette.Filter(0.1)
+	if len(filtered) == 0 || len(filtered) == len(palette) {
+		t.Fatalf("expected some swatches below 10%%, got %v", palette)
+	}
+	for _, s := range filtered {
+		if s.Share < 0.1 {
+			t.Errorf("swatch %v should be filtered out", s)
+		}
+	}
+}
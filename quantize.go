@@ -0,0 +1,95 @@
+package color_thief
+
+import (
+	"color-thief/dither"
+	"color-thief/helper"
+	"color-thief/quantizer"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// Quantize return the image remapped onto a palette of at most k colors along with the palette.
+// The palette is computed from the sampled pixels like GetPaletteWithOptions, every pixel of the image
+// is then mapped to the box of its color by wu and to the nearest color of the palette otherwise,
+// after diffusing the quantization error as selected by WithDither.
+func Quantize(img image.Image, k int, opts ...Option) (*image.Paletted, Palette, error) {
+	return QuantizeContext(context.Background(), img, k, opts...)
+}
+
+// QuantizeContext is Quantize that gives up with the context error once ctx is done
+func QuantizeContext(ctx context.Context, img image.Image, k int, opts ...Option) (*image.Paletted, Palette, error) {
+	o := newOptions(opts)
+	o.NumColors = k
+
+	if k > 256 {
+		return nil, nil, fmt.Errorf("%w: a paletted image holds at most 256 colors, got %d", ErrInvalidColorCount, k)
+	}
+
+	palette, index, err := extract(ctx, img, &o, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if o.Depth == 16 {
+		// the image is remapped from its 8-bit colors
+		index = widenIndex(index)
+	}
+
+	src := toRGBA(img, o.sampling().Bounds(img))
+	if o.Background != nil {
+		src = helper.CompositeRGBA(src, o.Background)
+	}
+
+	dst, err := remap(ctx, src, palette, index, o.Dither)
+	if err != nil {
+		return nil, nil, err
+	}
+	return dst, palette, nil
+}
+
+// remap map every pixel of the image onto the palette
+func remap(ctx context.Context, src *image.RGBA, palette Palette, index quantizer.IndexFunc, opts dither.Options) (*image.Paletted, error) {
+	var x, y, offset int
+	var c [3]int
+
+	bounds := src.Bounds()
+	dst := image.NewPaletted(bounds, palette.Colors())
+	if opts.Method != dither.None {
+		if err := dither.ApplyContext(ctx, dst, src, palette.colors(), dither.IndexFunc(index), opts); err != nil {
+			return nil, err
+		}
+		return dst, nil
+	}
+
+	for y = bounds.Min.Y; y < bounds.Max.Y; y++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		offset = src.PixOffset(bounds.Min.X, y)
+		for x = 0; x < bounds.Dx(); x++ {
+			c[0], c[1], c[2] = int(src.Pix[offset]), int(src.Pix[offset+1]), int(src.Pix[offset+2])
+			dst.Pix[dst.PixOffset(bounds.Min.X+x, y)] = uint8(index(c))
+			offset += 4
+		}
+	}
+	return dst, nil
+}
+
+// widenIndex map 8-bit colors with the index of 16-bit colors
+func widenIndex(index quantizer.IndexFunc) quantizer.IndexFunc {
+	return func(c [3]int) int {
+		return index([3]int{c[0] * 0x101, c[1] * 0x101, c[2] * 0x101})
+	}
+}
+
+// toRGBA return the part r of the image as an *image.RGBA, converting it when needed
+func toRGBA(img image.Image, r image.Rectangle) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba.SubImage(r).(*image.RGBA)
+	}
+	rgba := image.NewRGBA(r)
+	draw.Draw(rgba, r, img, r.Min, draw.Src)
+	return rgba
+}
@@ -0,0 +1,66 @@
+package color_thief
+
+import (
+	"color-thief/dither"
+	"color-thief/quantizer"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestQuantize(t *testing.T) {
+	for _, name := range []string{quantizer.Wu, quantizer.WSM} {
+		dst, palette, err := Quantize(img, 16, WithAlgorithm(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dst.Bounds() != img.Bounds() || len(dst.Palette) != len(palette) {
+			t.Fatalf("%s: unexpected paletted image %v with %d colors", name, dst.Bounds(), len(dst.Palette))
+		}
+
+		// the sampled pixels of the remapped image follow the populations of the palette
+		counts := make([]int, len(palette))
+		bounds := dst.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y += 2 {
+			for x := bounds.Min.X; x < bounds.Max.X; x += 2 {
+				counts[dst.ColorIndexAt(x, y)]++
+			}
+		}
+		for i, s := range palette {
+			if name == quantizer.Wu && counts[i] != s.Population {
+				t.Errorf("wu: swatch %v should hold %d sampled pixels, got %d", s, s.Population, counts[i])
+			}
+			if counts[i] == 0 {
+				t.Errorf("%s: swatch %v is not used by the remapped image", name, s)
+			}
+		}
+	}
+
+	if _, _, err := Quantize(img, 300); !errors.Is(err, ErrInvalidColorCount) {
+		t.Errorf("expected ErrInvalidColorCount for 300 colors, got %v", err)
+	}
+}
+
+func TestQuantizeDither(t *testing.T) {
+	plain, palette, err := Quantize(img, 8, WithAlgorithm(quantizer.WSM))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for m := dither.FloydSteinberg; m <= dither.Riemersma; m++ {
+		dst, dithered, err := Quantize(img, 8, WithAlgorithm(quantizer.WSM), WithDither(m, 0.8), WithSerpentine())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(palette, dithered) {
+			t.Errorf("%v: dithering should not change the palette", m)
+		}
+		if reflect.DeepEqual(dst.Pix, plain.Pix) {
+			t.Errorf("%v: dithered image should differ from the plain remapping", m)
+		}
+	}
+
+	if _, _, err = Quantize(img, 8, WithDither(dither.Atkinson, 1.5)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("expected ErrInvalidOption for strength 1.5, got %v", err)
+	}
+}
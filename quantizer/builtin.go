@@ -0,0 +1,63 @@
+package quantizer
+
+import (
+	"color-thief/wsm"
+	"color-thief/wu"
+	"context"
+	"fmt"
+)
+
+func init() {
+	Register(Wu, wuQuantizer{})
+	Register(WSM, wsmQuantizer{})
+}
+
+// wuQuantizer is Xiaolin Wu's color quantizer, it maps colors to the palette through its boxes
+type wuQuantizer struct{}
+
+func (wuQuantizer) Quantize(ctx context.Context, pixels [][3]int, k int, cfg Config) ([]Cluster, error) {
+	clusters, _, err := wuQuantizer{}.QuantizeIndex(ctx, pixels, k, cfg)
+	return clusters, err
+}
+
+func (wuQuantizer) QuantizeIndex(ctx context.Context, pixels [][3]int, k int, cfg Config) ([]Cluster, IndexFunc, error) {
+	if !cfg.ColorSpace.Valid() {
+		return nil, nil, fmt.Errorf("wu: %w %v", ErrUnsupportedColorSpace, cfg.ColorSpace)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	result, err := wu.QuantizeOptions(pixels, k, wu.Options{Depth: cfg.Depth, Space: cfg.ColorSpace, Weights: cfg.Weights, HistBits: cfg.HistBits})
+	if err != nil {
+		return nil, nil, err
+	}
+	return clusters(result.Palette, result.Counts, result.Variances), result.Index, nil
+}
+
+// wsmQuantizer is the weighted sort-means refinement of the wu palette
+type wsmQuantizer struct{}
+
+func (wsmQuantizer) Quantize(ctx context.Context, pixels [][3]int, k int, cfg Config) ([]Cluster, error) {
+	if !cfg.ColorSpace.Valid() {
+		return nil, fmt.Errorf("wsm: %w %v", ErrUnsupportedColorSpace, cfg.ColorSpace)
+	}
+	result, err := wsm.QuantizeContext(ctx, pixels, k, wsm.Options{
+		MaxIterations: cfg.MaxIterations,
+		Tolerance:     cfg.Tolerance,
+		HistBits:      cfg.HistBits,
+		Depth:         cfg.Depth,
+		Space:         cfg.ColorSpace,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return clusters(result.Palette, result.Counts, result.Variances), nil
+}
+
+func clusters(palette [][3]int, counts []int, variances []float64) []Cluster {
+	c := make([]Cluster, len(palette))
+	for i := range palette {
+		c[i] = Cluster{Color: palette[i], Count: counts[i], Variance: variances[i]}
+	}
+	return c
+}
@@ -0,0 +1,186 @@
+package quantizer
+
+import (
+	"color-thief/colorspace"
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrUnsupportedColorSpace is returned by quantizers asked to cluster in a color space they do not handle
+var ErrUnsupportedColorSpace = errors.New("unsupported color space")
+
+// names of the built-in quantizers
+const (
+	Wu  = "wu"
+	WSM = "wsm"
+)
+
+// Config carries the tuning knobs of a quantization, a quantizer ignores the ones it has no use for
+// and treats zero values as its defaults
+type Config struct {
+	MaxIterations int     // iteration cap of iterative algorithms such as wsm
+	Tolerance     float64 // loss improvement below which an iterative algorithm stops
+	HistBits      int     // histogram precision per channel
+	ColorSpace    colorspace.Space
+	Depth         int        // bits per channel of the pixels, 8 when zero or 16
+	Weights       [3]float64 // weight of the squared error along each channel in wu's box splitting, zero for unit weights
+}
+
+// Cluster is a color of the palette along with the pixels it stands for
+type Cluster struct {
+	Color    [3]int
+	Count    int     // number of pixels mapped to the color
+	Variance float64 // mean squared distance of these pixels to the color, in the color space and weights of cfg
+}
+
+// Quantizer reduces the sampled pixels of an image to a palette of at most k colors,
+// sorted from the most to the least dominant one. Long-running quantizers should give up
+// with ctx.Err() once ctx is done.
+type Quantizer interface {
+	Quantize(ctx context.Context, pixels [][3]int, k int, cfg Config) ([]Cluster, error)
+}
+
+// Func adapts an ordinary function to the Quantizer interface
+type Func func(ctx context.Context, pixels [][3]int, k int, cfg Config) ([]Cluster, error)
+
+// Quantize calls f(ctx, pixels, k, cfg)
+func (f Func) Quantize(ctx context.Context, pixels [][3]int, k int, cfg Config) ([]Cluster, error) {
+	return f(ctx, pixels, k, cfg)
+}
+
+// IndexFunc return the palette index of a color
+type IndexFunc func(c [3]int) int
+
+// Indexer is implemented by quantizers that know which palette entry stands for any color,
+// such as wu through its box tags, sparing a nearest color search when an image is remapped
+type Indexer interface {
+	QuantizeIndex(ctx context.Context, pixels [][3]int, k int, cfg Config) ([]Cluster, IndexFunc, error)
+}
+
+// QuantizeIndex run q and return the clusters along with a function mapping any color to them,
+// the mapping of q is used when it implements Indexer and a nearest color search otherwise
+func QuantizeIndex(ctx context.Context, q Quantizer, pixels [][3]int, k int, cfg Config) ([]Cluster, IndexFunc, error) {
+	if indexer, ok := q.(Indexer); ok {
+		return indexer.QuantizeIndex(ctx, pixels, k, cfg)
+	}
+
+	clusters, err := q.Quantize(ctx, pixels, k, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	palette := make([][3]int, len(clusters))
+	for i, c := range clusters {
+		palette[i] = c.Color
+	}
+	return clusters, Nearest(palette), nil
+}
+
+// nearestCacheSize bounds the number of colors remembered by Nearest, dithering produces many distinct colors
+const nearestCacheSize = 1 << 16
+
+// Nearest return an IndexFunc searching the palette for the color at the smallest euclidean distance,
+// the results are cached so the function must not be shared between goroutines
+func Nearest(palette [][3]int) IndexFunc {
+	cache := make(map[[3]int]int)
+	return func(c [3]int) int {
+		i, ok := cache[c]
+		if !ok {
+			i, _ = nearest(palette, c)
+			if len(cache) >= nearestCacheSize {
+				cache = make(map[[3]int]int)
+			}
+			cache[c] = i
+		}
+		return i
+	}
+}
+
+// Assign map every pixel to its nearest color of the palette and return the resulting clusters
+// in palette order. It lets quantizers that only produce colors report their statistics.
+func Assign(pixels [][3]int, palette [][3]int) []Cluster {
+	var i, j int
+	var dist int
+	var clusters []Cluster
+
+	clusters = make([]Cluster, len(palette))
+	for i = range palette {
+		clusters[i].Color = palette[i]
+	}
+	if len(palette) == 0 {
+		return clusters
+	}
+
+	for i = range pixels {
+		j, dist = nearest(palette, pixels[i])
+		clusters[j].Count++
+		clusters[j].Variance += float64(dist)
+	}
+
+	for i = range clusters {
+		if clusters[i].Count > 0 {
+			clusters[i].Variance /= float64(clusters[i].Count)
+		}
+	}
+	return clusters
+}
+
+// nearest return the index of the color of the palette closest to c along with their squared distance
+func nearest(palette [][3]int, c [3]int) (int, int) {
+	var i, j, dist, minDist int
+
+	minDist = sqDistance(c, palette[0])
+	for j = 1; j < len(palette); j++ {
+		if dist = sqDistance(c, palette[j]); dist < minDist {
+			i, minDist = j, dist
+		}
+	}
+	return i, minDist
+}
+
+func sqDistance(p1, p2 [3]int) int {
+	dr, dg, db := p1[0]-p2[0], p1[1]-p2[1], p1[2]-p2[2]
+	return dr*dr + dg*dg + db*db
+}
+
+var (
+	mu       sync.RWMutex
+	registry = make(map[string]Quantizer)
+	order    []string // registration order, the wasm module selects quantizers by index
+)
+
+// Register makes a quantizer available by the provided name.
+// If Register is called twice with the same name or if q is nil, it panics.
+func Register(name string, q Quantizer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if q == nil {
+		panic("quantizer: Register quantizer is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("quantizer: Register called twice for quantizer " + name)
+	}
+	registry[name] = q
+	order = append(order, name)
+}
+
+// Lookup returns the quantizer registered under name
+func Lookup(name string) (Quantizer, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	q, ok := registry[name]
+	return q, ok
+}
+
+// Names returns the names of the registered quantizers in registration order,
+// the built-in "wu" and "wsm" always come first
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, len(order))
+	copy(names, order)
+	return names
+}
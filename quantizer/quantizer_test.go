@@ -0,0 +1,112 @@
+package quantizer
+
+import (
+	"color-thief/helper"
+	"color-thief/wu"
+	"context"
+	"log"
+	"reflect"
+	"testing"
+)
+
+var p [][3]int
+
+func init() {
+	img, err := helper.ReadImage("../example/photo1.jpg")
+	if err != nil {
+		log.Fatal(err)
+	}
+	p = helper.SubsamplingPixelsFromImage(img)
+}
+
+func TestBuiltin(t *testing.T) {
+	names := Names()
+	if len(names) < 2 || names[0] != Wu || names[1] != WSM {
+		t.Fatalf("unexpected built-in quantizers: %v", names)
+	}
+
+	q, ok := Lookup(Wu)
+	if !ok {
+		t.Fatal("wu quantizer not registered")
+	}
+	clusters, err := q.Quantize(context.Background(), p, 6, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	palette := make([][3]int, len(clusters))
+	for i, c := range clusters {
+		palette[i] = c.Color
+	}
+	if !reflect.DeepEqual(palette, wu.QuantWu(p, 6)) {
+		t.Error("wu quantizer differs from wu.QuantWu")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("first", Func(func(ctx context.Context, pixels [][3]int, k int, cfg Config) ([]Cluster, error) {
+		return Assign(pixels, pixels[:k]), nil
+	}))
+
+	q, ok := Lookup("first")
+	if !ok {
+		t.Fatal("registered quantizer not found")
+	}
+	if clusters, _ := q.Quantize(context.Background(), p, 2, Config{}); clusters[0].Color != p[0] || clusters[1].Color != p[1] {
+		t.Errorf("unexpected palette %v", clusters)
+	}
+	if names := Names(); names[len(names)-1] != "first" {
+		t.Errorf("registered quantizer is not listed last: %v", names)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice should panic")
+		}
+	}()
+	Register(Wu, wuQuantizer{})
+}
+
+func TestAssign(t *testing.T) {
+	pixels := [][3]int{{0, 0, 0}, {2, 0, 0}, {250, 250, 250}, {10, 0, 0}}
+	clusters := Assign(pixels, [][3]int{{255, 255, 255}, {4, 0, 0}})
+
+	expected := []Cluster{
+		{Color: [3]int{255, 255, 255}, Count: 1, Variance: 75},
+		{Color: [3]int{4, 0, 0}, Count: 3, Variance: (16 + 4 + 36) / 3.0},
+	}
+	if !reflect.DeepEqual(clusters, expected) {
+		t.Errorf("expected %v, got %v", expected, clusters)
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, name := range Names()[:2] {
+		q, _ := Lookup(name)
+		if _, err := q.Quantize(ctx, p, 6, Config{}); err != context.Canceled {
+			t.Errorf("%s: expected context.Canceled, got %v", name, err)
+		}
+	}
+}
+
+func TestQuantizeIndex(t *testing.T) {
+	for _, name := range Names()[:2] {
+		q, _ := Lookup(name)
+		clusters, index, err := QuantizeIndex(context.Background(), q, p, 6, Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, c := range clusters {
+			if idx := index(c.Color); idx != i {
+				t.Errorf("%s: color %v should map to itself (%d), got %d", name, c.Color, i, idx)
+			}
+		}
+	}
+
+	index := Nearest([][3]int{{0, 0, 0}, {255, 255, 255}})
+	if index([3]int{100, 100, 100}) != 0 || index([3]int{200, 100, 200}) != 1 {
+		t.Error("unexpected nearest color")
+	}
+}
@@ -0,0 +1,167 @@
+package sampler
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Sampler picks the pixels of a width x height area that are taken into account
+type Sampler interface {
+	// Size return an upper bound of the number of sampled pixels
+	Size(width, height int) int
+	// Sample call visit with the coordinates of every sampled pixel, relative to the top-left corner of the area
+	Sample(width, height int, visit func(x, y int))
+}
+
+// Stride take every n-th pixel in the horizontal and vertical directions, like the quality parameter
+// of the original Color Thief. Stride(2) is the 2:1 subsampling of the paper (2.2.1).
+type Stride int
+
+func (s Stride) step() int {
+	if s < 1 {
+		return 1
+	}
+	return int(s)
+}
+
+func (s Stride) Size(width, height int) int {
+	step := s.step()
+	return ((width + step - 1) / step) * ((height + step - 1) / step)
+}
+
+func (s Stride) Sample(width, height int, visit func(x, y int)) {
+	var x, y int
+
+	step := s.step()
+	for y = 0; y < height; y += step {
+		for x = 0; x < width; x += step {
+			visit(x, y)
+		}
+	}
+}
+
+// Full take every pixel into account
+type Full struct{}
+
+func (Full) Size(width, height int) int {
+	return width * height
+}
+
+func (Full) Sample(width, height int, visit func(x, y int)) {
+	Stride(1).Sample(width, height, visit)
+}
+
+// Budget take at most n pixels on a regular grid whatever the resolution,
+// images of n pixels or less are fully scanned
+type Budget int
+
+// stride return the smallest stride yielding at most n samples
+func (b Budget) stride(width, height int) Stride {
+	if b < 1 || width*height <= int(b) {
+		return 1
+	}
+	s := Stride(math.Ceil(math.Sqrt(float64(width*height) / float64(b))))
+	for s.Size(width, height) > int(b) {
+		s++
+	}
+	return s
+}
+
+func (b Budget) Size(width, height int) int {
+	return b.stride(width, height).Size(width, height)
+}
+
+func (b Budget) Sample(width, height int, visit func(x, y int)) {
+	b.stride(width, height).Sample(width, height, visit)
+}
+
+// Random take N distinct pixels uniformly at random, the same seed yields the same pixels.
+// They are visited row by row. Areas of N pixels or less are fully scanned, a negative N takes none.
+type Random struct {
+	N    int
+	Seed int64
+}
+
+func (r Random) n() int {
+	if r.N < 0 {
+		return 0
+	}
+	return r.N
+}
+
+func (r Random) Size(width, height int) int {
+	if r.n() < width*height {
+		return r.n()
+	}
+	return width * height
+}
+
+func (r Random) Sample(width, height int, visit func(x, y int)) {
+	var i, t int64
+
+	n := int64(r.n())
+	total := int64(width * height)
+	if n >= total {
+		Full{}.Sample(width, height, visit)
+		return
+	}
+
+	// Robert Floyd's sampling without replacement
+	rng := rand.New(rand.NewSource(r.Seed))
+	chosen := make(map[int64]struct{}, n)
+	for i = total - n; i < total; i++ {
+		t = rng.Int63n(i + 1)
+		if _, ok := chosen[t]; ok {
+			t = i
+		}
+		chosen[t] = struct{}{}
+	}
+
+	positions := make([]int64, 0, len(chosen))
+	for t = range chosen {
+		positions = append(positions, t)
+	}
+	sort.Slice(positions, func(a, b int) bool { return positions[a] < positions[b] })
+	for _, t = range positions {
+		visit(int(t%int64(width)), int(t/int64(width)))
+	}
+}
+
+// Jittered split the area into Cell x Cell blocks and take one pixel at random within each of them,
+// it covers the image as evenly as a stride without aliasing on regular patterns
+type Jittered struct {
+	Cell int
+	Seed int64
+}
+
+func (j Jittered) cell() int {
+	if j.Cell < 1 {
+		return 1
+	}
+	return j.Cell
+}
+
+func (j Jittered) Size(width, height int) int {
+	return Stride(j.cell()).Size(width, height)
+}
+
+func (j Jittered) Sample(width, height int, visit func(x, y int)) {
+	var x, y, w, h int
+
+	cell := j.cell()
+	rng := rand.New(rand.NewSource(j.Seed))
+	for y = 0; y < height; y += cell {
+		h = cell
+		if y+h > height {
+			h = height - y
+		}
+		for x = 0; x < width; x += cell {
+			w = cell
+			if x+w > width {
+				w = width - x
+			}
+			visit(x+rng.Intn(w), y+rng.Intn(h))
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package sampler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func positions(s Sampler, width, height int) [][2]int {
+	var p [][2]int
+	s.Sample(width, height, func(x, y int) {
+		p = append(p, [2]int{x, y})
+	})
+	return p
+}
+
+func TestSamplers(t *testing.T) {
+	const width, height = 37, 23
+
+	for _, s := range []Sampler{Stride(1), Stride(2), Stride(5), Full{}, Budget(100), Budget(1 << 20),
+		Random{N: 300, Seed: 1}, Random{N: 5000}, Random{N: -1}, Jittered{Cell: 4, Seed: 1}, Jittered{Cell: 50}} {
+		p := positions(s, width, height)
+		if len(p) != s.Size(width, height) {
+			t.Errorf("%#v: expected %d samples, got %d", s, s.Size(width, height), len(p))
+		}
+
+		seen := make(map[[2]int]bool)
+		for _, xy := range p {
+			if xy[0] < 0 || xy[0] >= width || xy[1] < 0 || xy[1] >= height {
+				t.Fatalf("%#v: sample %v out of bounds", s, xy)
+			}
+			if seen[xy] {
+				t.Fatalf("%#v: sample %v taken twice", s, xy)
+			}
+			seen[xy] = true
+		}
+
+		if !reflect.DeepEqual(p, positions(s, width, height)) {
+			t.Errorf("%#v: samples differ between runs", s)
+		}
+	}
+
+	if n := Budget(100).Size(width, height); n > 100 || n < 50 {
+		t.Errorf("budget of 100 samples yields %d", n)
+	}
+	if n := Budget(1<<20).Size(width, height); n != width*height {
+		t.Errorf("small images should be fully scanned within the budget, got %d samples", n)
+	}
+	if reflect.DeepEqual(positions(Random{N: 300, Seed: 1}, width, height), positions(Random{N: 300, Seed: 2}, width, height)) {
+		t.Error("different seeds should pick different pixels")
+	}
+
+	// one sample within each 4x4 cell
+	cells := make(map[[2]int]int)
+	for _, xy := range positions(Jittered{Cell: 4, Seed: 3}, width, height) {
+		cells[[2]int{xy[0] / 4, xy[1] / 4}]++
+	}
+	if len(cells) != 10*6 {
+		t.Errorf("expected one sample in each of the 60 cells, got %d cells", len(cells))
+	}
+}
+
+func BenchmarkRandom(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		Random{N: 100000, Seed: int64(i)}.Sample(4000, 3000, func(x, y int) {})
+	}
+}
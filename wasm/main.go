@@ -2,8 +2,9 @@ package main
 
 import (
 	"color-thief/helper"
-	"color-thief/wsm"
-	"color-thief/wu"
+	"color-thief/quantizer"
+	"context"
+	"image/color"
 )
 
 func main() {}
@@ -11,6 +12,8 @@ func main() {}
 var (
 	buffer   []uint8
 	palettes []uint8
+	sampling = helper.DefaultSampling
+	format   = helper.PixelFormat{Layout: helper.RGBA}
 )
 
 // Function to init our buffer in wasm memory
@@ -43,30 +46,56 @@ func getPalettesBufferPointer() *uint8 {
 	return &palettes[0]
 }
 
-// Function to return palettes compute from input image
+// Function to skip the pixels whose alpha is below threshold and, when composite is set,
+// to blend the translucent ones over the background color (r, g, b)
+//export setAlphaHandling
+func setAlphaHandling(threshold, composite, r, g, b int) {
+	sampling.AlphaThreshold = uint8(threshold)
+	sampling.Background = nil
+	if composite != 0 {
+		sampling.Background = color.RGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: 255}
+	}
+}
+
+// Function to set the layout of the input buffer (0 = rgba, 1 = bgra, 2 = rgb24, 3 = rgb565, 4 = gray8,
+// 5 = yuv420, 6 = nv12) along with its row strides, 0 for packed rows
+//export setPixelFormat
+func setPixelFormat(layout, stride, chromaStride int) {
+	format = helper.PixelFormat{Layout: helper.Layout(layout), Stride: stride, ChromaStride: chromaStride}
+}
+
+// Function to return the size of the input buffer for a w x h image in the current pixel format
+//export getBufferSize
+func getBufferSize(w, h int) int {
+	return format.BufferSize(w, h)
+}
+
+// Function to return palettes compute from input image,
+// s is the index of the quantizer in registration order (0 = wu, 1 = wsm)
 //export getPalette
 func getPalette(w, h, k, s int) int {
-	if k < 1 || (s != 0 && s != 1) {
+	names := quantizer.Names()
+	if k < 1 || s < 0 || s >= len(names) {
 		return 0
 	}
 
-	var pixels, palette [][3]int
-
-	pixels = helper.SubsamplingPixels(buffer, w, h)
+	var pixels [][3]int
 
-	switch s {
-	case 0:
-		palette = wu.QuantWu(pixels, k)
-		break
-	case 1:
-		palette = wsm.WSM(pixels, k)
-		break
-	default:
+	q, _ := quantizer.Lookup(names[s])
+	pixels = helper.SamplingPixelsFormat(buffer, w, h, format, sampling)
+	if len(pixels) == 0 {
+		return 0
+	}
+	clusters, err := q.Quantize(context.Background(), pixels, k, quantizer.Config{})
+	if err != nil {
 		return 0
 	}
 
-	for i, v := range palette {
-		palettes[3*i], palettes[3*i+1], palettes[3*i+2] = uint8(v[0]), uint8(v[1]), uint8(v[2])
+	for i := range palettes {
+		palettes[i] = 0 // colors the image does not hold stay black
+	}
+	for i, c := range clusters {
+		palettes[3*i], palettes[3*i+1], palettes[3*i+2] = uint8(c.Color[0]), uint8(c.Color[1]), uint8(c.Color[2])
 	}
 	return 1
 }
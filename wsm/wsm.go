@@ -2,89 +2,200 @@ package wsm
 
 import (
 	"color-thief/argsort"
+	"color-thief/colorspace"
 	"color-thief/wu"
+	"context"
+	"fmt"
 	"math"
 )
 
 const (
-	HistBits = 5
-	Shift    = 8 - HistBits
-	HistSize = 1 << (3 * HistBits)
+	HistBits    = 5
+	Shift       = 8 - HistBits
+	HistSize    = 1 << (3 * HistBits)
+	MinHistBits = 4
+	MaxHistBits = 8
+
+	MaxIterations = 100  // default iteration cap of the k-means refinement
+	Tolerance     = 1e-3 // default loss improvement below which k-means is considered converged
 )
 
+// Options tunes the k-means refinement, zero fields fall back to the package defaults
+type Options struct {
+	MaxIterations int
+	Tolerance     float64
+	HistBits      int              // histogram precision per channel, 4 to 8
+	Depth         int              // bits per channel of the pixels, 8 by default up to 16
+	Space         colorspace.Space // space in which the colors are assigned and averaged, RGB by default
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxIterations == 0 {
+		o.MaxIterations = MaxIterations
+	}
+	if o.Tolerance == 0 {
+		o.Tolerance = Tolerance
+	}
+	if o.HistBits == 0 {
+		o.HistBits = HistBits
+	}
+	if o.Depth == 0 {
+		o.Depth = 8
+	}
+	return o
+}
+
 // encode image pixels to 1d histogram with weight proportion to its frequency
 // normalize by the total number of pixels
-func getHistogram(src [][3]int, size float64, pixels *[HistSize][3]float64, hist *[HistSize]float64) {
-	var ind, r, g, b, i int
-	var inr, ing, inb int
+// only the occupied cells are kept, in the order of their index, along with the last pixel falling in each
+func getHistogram(src [][3]int, size float64, bits, depth int) (cells []int, pixels [][3]float64, hist []float64) {
+	var ind, i, n int
+	var shift int
+	var last []int32 // 1 + index of the last pixel of every cell, then 1 + index of the occupied cell
+
+	shift = depth - bits
+	cell := func(c [3]int) int {
+		return ((c[0] >> shift) << (2 * bits)) + ((c[1] >> shift) << bits) + (c[2] >> shift)
+	}
 
+	last = make([]int32, 1<<(3*bits))
 	for i = range src {
-		r = src[i][0]
-		g = src[i][1]
-		b = src[i][2]
+		last[cell(src[i])] = int32(i + 1)
+	}
+	for ind = range last {
+		if last[ind] != 0 {
+			n++
+		}
+	}
 
-		inr = r >> Shift
-		ing = g >> Shift
-		inb = b >> Shift
+	cells = make([]int, 0, n)
+	pixels = make([][3]float64, 0, n)
+	for ind = range last {
+		if last[ind] == 0 {
+			continue
+		}
+		c := src[last[ind]-1]
+		cells = append(cells, ind)
+		pixels = append(pixels, [3]float64{float64(c[0]), float64(c[1]), float64(c[2])})
+		last[ind] = int32(len(cells))
+	}
 
-		ind = (inr << (2 * HistBits)) + (ing << HistBits) + inb
-		pixels[ind][0], pixels[ind][1], pixels[ind][2] = float64(r), float64(g), float64(b)
-		hist[ind]++
+	hist = make([]float64, n)
+	for i = range src {
+		hist[last[cell(src[i])]-1]++
 	}
 
 	// normalize weight by the number of pixels in the image
-	for i = 0; i < HistSize; i++ {
+	for i = range hist {
 		hist[i] /= size
 	}
+	return cells, pixels, hist
 }
 
+// Result is the palette of a quantization along with the statistics of each color
+type Result struct {
+	Palette   [][3]int  // colors sorted by decreasing pixel count
+	Counts    []int     // number of pixels assigned to each color
+	Variances []float64 // mean squared distance of these pixels to their color, in the color space
+}
+
+// WSM quantize the pixels with the default options, the palette is padded with black
+// when the pixels do not hold enough colors, use Quantize to have the errors reported instead
 func WSM(src [][3]int, k int) [][3]int {
+	palette := make([][3]int, k)
+	if result, err := Quantize(src, k, Options{}); err == nil {
+		copy(palette, result.Palette)
+	}
+	return palette
+}
+
+// Quantize refine the wu color quantization result with weighted sort-means
+func Quantize(src [][3]int, k int, opts Options) (*Result, error) {
+	return QuantizeContext(context.Background(), src, k, opts)
+}
+
+// QuantizeContext is Quantize that gives up with the context error once ctx is done,
+// the context is checked before every k-means iteration
+func QuantizeContext(ctx context.Context, src [][3]int, k int, opts Options) (*Result, error) {
 	// variables
 	var centroids [][3]float64          // centroid list with size of k
 	var d []float64                     // distance matrix
 	var m []int                         // distance rank matrix
-	var hist [HistSize]float64          // image encoded histogram
-	var pixels [HistSize][3]float64     // encoded unique pixels
-	var p2c [HistSize]int               // pointer to centroid index
+	var hist []float64                  // image encoded histogram
+	var pixels [][3]float64             // encoded unique pixels
+	var cells []int                     // histogram index of the unique pixels
+	var p2c []int                       // pointer to centroid index
 	var cR, cG, cB, cW, cSize []float64 // use when computing new centroids
+	var cVar []float64                  // squared distance of the pixels to their centroid
 	var nR, nG, nB float64              // new centroid r,g,b
-	var palette [][3]int                // palette container
+	var initial *wu.Result              // wu color quantization result
+	var result *Result                  // palette container
 	var cPix [3]float64                 // pixel with float
 	var pix [3]int                      // pixel with int
 	var rank []int                      // palette usage count
 	var dist, minDist, prevDist float64
 	var loss, tempLoss float64
 	var size, w float64
-	var iter, i, j int
+	var iter, i, j, c int
 	var p, t int
+	var wuBits int
+	var err error
+
+	opts = opts.withDefaults()
+	if opts.HistBits < MinHistBits || opts.HistBits > MaxHistBits {
+		return nil, fmt.Errorf("histogram bits should be between %d and %d, got %d", MinHistBits, MaxHistBits, opts.HistBits)
+	}
+	if opts.Depth < opts.HistBits || opts.Depth < 5 || opts.Depth > 16 {
+		return nil, fmt.Errorf("channel depth should be between 5 and 16 bits and not below the histogram bits, got %d", opts.Depth)
+	}
+	if !opts.Space.Valid() {
+		return nil, fmt.Errorf("unsupported color space %v", opts.Space)
+	}
+	if opts.MaxIterations < 0 || opts.Tolerance < 0 {
+		return nil, fmt.Errorf("iteration limit and tolerance should not be negative")
+	}
 
 	// get histogram
 	size = float64(len(src))
-	getHistogram(src, size, &pixels, &hist)
+	cells, pixels, hist = getHistogram(src, size, opts.HistBits, opts.Depth)
+	p2c = make([]int, len(hist))
+	if opts.Space != colorspace.RGB {
+		for i = range pixels {
+			pixels[i] = toSpace(pixels[i], opts.Depth, opts.Space)
+		}
+	}
 
-	// init cluster centers based on wu color quantization result
-	palette = wu.QuantWu(src, k)
+	// init cluster centers based on wu color quantization result, at the closest precision wu supports
+	wuBits = opts.HistBits
+	if wuBits < wu.MinHistBits {
+		wuBits = wu.MinHistBits
+	} else if wuBits > wu.MaxHistBits {
+		wuBits = wu.MaxHistBits
+	}
+	if initial, err = wu.QuantizeOptions(src, k, wu.Options{Depth: opts.Depth, Space: opts.Space, HistBits: wuBits}); err != nil {
+		return nil, err
+	}
 
 	// cannot produce enough color, create palette using color scheme
-	if len(palette) < k {
-		return palette
+	if len(initial.Palette) < k {
+		return &Result{Palette: initial.Palette, Counts: initial.Counts, Variances: initial.Variances}, nil
 	}
 
 	// init centroids
 	centroids = make([][3]float64, k)
-	for i, pix = range palette {
+	for i, pix = range initial.Palette {
 		centroids[i][0], centroids[i][1], centroids[i][2] = float64(pix[0]), float64(pix[1]), float64(pix[2])
+		if opts.Space != colorspace.RGB {
+			centroids[i] = toSpace(centroids[i], opts.Depth, opts.Space)
+		}
 	}
 
 	// random assign centroids to each pixels
-	for i = 0; i < HistSize; i++ {
-		if hist[i] == 0 {
-			continue
-		}
-		p2c[i] = i % k
+	for i = range hist {
+		p2c[i] = cells[i] % k
 	}
 
-	loss = 1e6
+	loss = math.Ldexp(1e6, opts.Depth-8) // in the unit of the channels
 	d = make([]float64, k*k)
 	m = make([]int, k*k)
 	cR = make([]float64, k)
@@ -93,7 +204,11 @@ func WSM(src [][3]int, k int) [][3]int {
 	cW = make([]float64, k)
 	cSize = make([]float64, k)
 	// default 100 iterations for k-means
-	for iter = 0; iter < 100; iter++ {
+	for iter = 0; iter < opts.MaxIterations; iter++ {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		// compute distance matrix
 		for i = 0; i < k; i++ {
 			for j = i + 1; j < k; j++ {
@@ -110,9 +225,6 @@ func WSM(src [][3]int, k int) [][3]int {
 		}
 
 		for i, w = range hist {
-			if w == 0 {
-				continue
-			}
 			p = p2c[i]
 			cPix = pixels[i]
 			dist = distance(&cPix, &centroids[p])
@@ -137,9 +249,6 @@ func WSM(src [][3]int, k int) [][3]int {
 
 		// recalculate the cluster centers
 		for i, w = range hist {
-			if w == 0 {
-				continue
-			}
 			p = p2c[i]
 			cR[p] += pixels[i][0] * w // r
 			cG[p] += pixels[i][1] * w // g
@@ -160,27 +269,66 @@ func WSM(src [][3]int, k int) [][3]int {
 		// compute loss
 		tempLoss = 0
 		for i, w = range hist {
-			if w == 0 {
-				continue
-			}
 			p = p2c[i]
 			cPix = pixels[i]
 			dist = distance(&cPix, &centroids[p])
 			tempLoss += dist
 		}
 
-		if loss-tempLoss < 1e-3 {
+		if loss-tempLoss < opts.Tolerance {
 			break
 		}
 		loss = tempLoss
 	}
 
+	// spread of each cluster
+	cVar = make([]float64, k)
+	for i, w = range hist {
+		p = p2c[i]
+		cPix = pixels[i]
+		dist = distance(&cPix, &centroids[p])
+		cVar[p] += dist * dist * w
+	}
+
 	rank = argsort.Quicksort(cSize)
+	result = &Result{
+		Palette:   make([][3]int, 0, k),
+		Counts:    make([]int, 0, k),
+		Variances: make([]float64, 0, k),
+	}
 	for i = 0; i < k; i++ {
-		cPix = centroids[rank[k-1-i]]
-		palette[i][0], palette[i][1], palette[i][2] = int(cPix[0]), int(cPix[1]), int(cPix[2])
+		c = rank[k-1-i]
+		if cW[c] == 0 {
+			break // empty clusters rank last and have no center
+		}
+		cPix = centroids[c]
+		if opts.Space != colorspace.RGB {
+			cPix = fromSpace(cPix, opts.Depth, opts.Space)
+		}
+		pix[0], pix[1], pix[2] = int(cPix[0]), int(cPix[1]), int(cPix[2])
+		result.Palette = append(result.Palette, pix)
+		result.Counts = append(result.Counts, int(math.Round(cSize[c])))
+		result.Variances = append(result.Variances, cVar[c]/cW[c])
 	}
-	return palette
+	return result, nil
+}
+
+// toSpace convert a color whose channels are depth bits wide to the space,
+// scaled so that distances stay in the unit of the channels
+func toSpace(c [3]float64, depth int, space colorspace.Space) [3]float64 {
+	max := float64(int(1)<<depth - 1)
+	c = space.FromRGB([3]float64{c[0] / max, c[1] / max, c[2] / max})
+	return [3]float64{c[0] * max, c[1] * max, c[2] * max}
+}
+
+// fromSpace is the inverse of toSpace, the channels are clamped to the sRGB gamut and rounded
+func fromSpace(c [3]float64, depth int, space colorspace.Space) [3]float64 {
+	max := float64(int(1)<<depth - 1)
+	c = space.ToRGB([3]float64{c[0] / max, c[1] / max, c[2] / max})
+	for i := range c {
+		c[i] = math.Round(math.Max(0, math.Min(c[i], 1)) * max)
+	}
+	return c
 }
 
 func distance(p1, p2 *[3]float64) float64 {
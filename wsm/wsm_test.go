@@ -1,19 +1,22 @@
 package wsm
 
 import (
+	"color-thief/colorspace"
 	"color-thief/helper"
+	"image"
 	"log"
 	"reflect"
 	"testing"
 )
 
 var (
-	p1 [][3]int
+	img1 image.Image
+	p1   [][3]int
 )
 
 func init() {
 	var err error
-	img1, err := helper.ReadImage("../example/photo1.jpg")
+	img1, err = helper.ReadImage("../example/photo1.jpg")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -46,3 +49,128 @@ func BenchmarkWSM(b *testing.B) {
 		_ = WSM(p1, 6)
 	}
 }
+
+// BenchmarkWSMFromImage includes the sampling of the decoded JPEG
+func BenchmarkWSMFromImage(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = WSM(helper.SubsamplingPixelsFromImage(img1), 6)
+	}
+}
+
+func TestQuantizeOptions(t *testing.T) {
+	result, err := Quantize(p1, 6, Options{MaxIterations: MaxIterations, Tolerance: Tolerance, HistBits: HistBits})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Palette, WSM(p1, 6)) {
+		t.Error("explicit default options should match WSM")
+	}
+
+	if _, err = Quantize(p1, 6, Options{HistBits: 9}); err == nil {
+		t.Error("expected an error for 9 histogram bits")
+	}
+	if result, err = Quantize(p1, 6, Options{HistBits: 6, MaxIterations: 1}); err != nil || len(result.Palette) != 6 {
+		t.Errorf("unexpected result with 6 histogram bits: %v, %v", result, err)
+	}
+
+	// pixels filling fewer cells than colors give a shorter palette, WSM pads it
+	few := [][3]int{{255, 0, 0}, {0, 255, 0}, {0, 0, 255}}
+	if result, err = Quantize(few, 300, Options{}); err != nil || len(result.Palette) != 3 {
+		t.Errorf("expected 3 colors, got %v, %v", result, err)
+	}
+	if palette := WSM(few, 300); len(palette) != 300 || palette[2] == [3]int{} || palette[3] != [3]int{} {
+		t.Errorf("expected 3 colors padded with black, got %v", palette[:4])
+	}
+}
+
+func TestQuantizeStatistics(t *testing.T) {
+	result, err := Quantize(p1, 6, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	for i, c := range result.Counts {
+		if i > 0 && c > result.Counts[i-1] {
+			t.Errorf("colors are not sorted by count: %v", result.Counts)
+		}
+		if result.Variances[i] <= 0 {
+			t.Errorf("unexpected variance %v for color %v", result.Variances[i], result.Palette[i])
+		}
+		total += c
+	}
+	if total != len(p1) {
+		t.Errorf("counts should add up to %d pixels, got %d", len(p1), total)
+	}
+}
+
+func TestLinear(t *testing.T) {
+	checker := make([][3]int, 0, 64)
+	for i := 0; i < 32; i++ {
+		checker = append(checker, [3]int{255, 0, 0}, [3]int{0, 255, 0})
+	}
+	result, err := Quantize(checker, 1, Options{Space: colorspace.LinearRGB})
+	if err != nil || result.Palette[0] != [3]int{188, 188, 0} {
+		t.Errorf("expected the linear light mean, got %v, %v", result, err)
+	}
+
+	if result, err = Quantize(p1, 6, Options{Space: colorspace.LinearRGB}); err != nil || len(result.Palette) != 6 {
+		t.Errorf("unexpected result %v, %v", result, err)
+	}
+	if _, err = Quantize(p1, 6, Options{Space: colorspace.Space(-1)}); err == nil {
+		t.Error("expected an error for an unknown color space")
+	}
+}
+
+func TestColorSpaces(t *testing.T) {
+	// the statistics of the clusters formed in the space still cover every pixel
+	for _, space := range []colorspace.Space{colorspace.Lab, colorspace.OKLab, colorspace.YCbCr} {
+		result, err := Quantize(p1, 6, Options{Space: space})
+		if err != nil || len(result.Palette) != 6 {
+			t.Fatalf("%v: unexpected result %v, %v", space, result, err)
+		}
+		total := 0
+		for _, c := range result.Counts {
+			total += c
+		}
+		if total != len(p1) {
+			t.Errorf("%v: counts should add up to %d pixels, got %d", space, len(p1), total)
+		}
+	}
+	if _, err := Quantize(p1, 6, Options{Space: colorspace.Space(-1)}); err == nil {
+		t.Error("expected an error for an unknown color space")
+	}
+}
+
+func TestHistBits(t *testing.T) {
+	for bits := MinHistBits; bits <= MaxHistBits; bits++ {
+		result, err := Quantize(p1, 6, Options{HistBits: bits})
+		if err != nil || len(result.Palette) != 6 {
+			t.Fatalf("%d bits: unexpected result %v, %v", bits, result, err)
+		}
+		total := 0
+		for _, c := range result.Counts {
+			total += c
+		}
+		if total != len(p1) {
+			t.Errorf("%d bits: counts should add up to %d pixels, got %d", bits, len(p1), total)
+		}
+	}
+
+	// a subtle gradient only keeps its shades apart in a fine histogram
+	gradient := make([][3]int, 0, 64)
+	for i := 0; i < 64; i++ {
+		gradient = append(gradient, [3]int{200 + i%8, 150, 120})
+	}
+	if result, _ := Quantize(gradient, 2, Options{}); len(result.Palette) != 1 {
+		t.Errorf("expected the gradient to collapse into one color, got %v", result.Palette)
+	}
+	if result, _ := Quantize(gradient, 2, Options{HistBits: 8}); len(result.Palette) != 2 {
+		t.Errorf("expected the gradient to split at 8 bits, got %v", result.Palette)
+	}
+
+	if _, err := Quantize(p1, 6, Options{HistBits: 3}); err == nil {
+		t.Error("expected an error for 3 histogram bits")
+	}
+}
@@ -2,6 +2,10 @@ package wu
 
 import (
 	"color-thief/argsort"
+	"color-thief/colorspace"
+	"fmt"
+	"math"
+	"sync"
 )
 
 /**********************************************************************
@@ -11,11 +15,13 @@ import (
 **********************************************************************/
 
 const (
-	maxColor = 256
-	red      = 2
-	green    = 1
-	blue     = 0
-	cubeSize = 33 * 33 * 33
+	red   = 2
+	green = 1
+	blue  = 0
+
+	HistBits    = 5 // default histogram precision per channel
+	MinHistBits = 5
+	MaxHistBits = 7
 )
 
 type box struct {
@@ -28,8 +34,8 @@ type box struct {
 	vol int
 }
 
-func getColorIndex(r, g, b int) int {
-	return (r << 10) + (r << 6) + r + (g << 5) + g + b
+func getColorIndex(side, r, g, b int) int {
+	return (r*side+g)*side + b
 }
 
 /* Histogram is in elements 1..HISTSIZE along each axis,
@@ -37,36 +43,81 @@ func getColorIndex(r, g, b int) int {
  * NB: these must start out 0!
  */
 
+// histogram holds the moments over side cells along each axis, side is 1<<bits + 1
+type histogram struct {
+	bits, side     int
+	wt, mr, mg, mb []int
+	m2             []float64
+}
+
+// histograms recycle the moment tables, 7-bit tables take about 86MB
+var histograms [MaxHistBits + 1]sync.Pool
+
+// newHistogram return zeroed moment tables of the precision, release them once done
+func newHistogram(bits int) *histogram {
+	if h, ok := histograms[bits].Get().(*histogram); ok {
+		for i := range h.wt {
+			h.wt[i], h.mr[i], h.mg[i], h.mb[i], h.m2[i] = 0, 0, 0, 0, 0
+		}
+		return h
+	}
+	side := 1<<bits + 1
+	size := side * side * side
+	return &histogram{
+		bits: bits,
+		side: side,
+		wt:   make([]int, size),
+		mr:   make([]int, size),
+		mg:   make([]int, size),
+		mb:   make([]int, size),
+		m2:   make([]float64, size),
+	}
+}
+
+func (h *histogram) release() {
+	histograms[h.bits].Put(h)
+}
+
+func (h *histogram) index(r, g, b int) int {
+	return getColorIndex(h.side, r, g, b)
+}
+
 // hist3d  build 3-D color histogram of counts, r/g/b, c^2
-func hist3d(src [][3]int, size int, vwt, vmr, vmg, vmb *[cubeSize]int, m2 *[cubeSize]float64) []int {
+// the axes place the pixels in the histogram and give the values summed in the moments,
+// c^2 weighs the squares of the channels by w
+func (h *histogram) hist3d(src [][3]int, size int, a *axes, w *[3]float64) []int32 {
 	var i int
 	var ind, r, g, b int
 	var inr, ing, inb int // index for r,g,b
-	var table [256]int
-	var qadd []int
+	var v [3]int
+	var qadd []int32
 
-	for i = 0; i < 256; i++ {
-		table[i] = i * i
-	}
-
-	qadd = make([]int, size)
+	qadd = make([]int32, size)
 	for i = 0; i < size; i++ {
 		r = src[i][0]
 		g = src[i][1]
 		b = src[i][2]
 
-		inr = (r >> 3) + 1
-		ing = (g >> 3) + 1
-		inb = (b >> 3) + 1
+		if a.cell[0] != 0 {
+			ind, v = a.project(src[i])
+			r, g, b = v[0], v[1], v[2]
+		} else {
+			inr = (r >> a.shift) + 1
+			ing = (g >> a.shift) + 1
+			inb = (b >> a.shift) + 1
+			ind = h.index(inr, ing, inb)
+			if a.linear != nil {
+				r, g, b = a.linear[r], a.linear[g], a.linear[b]
+			}
+		}
 
-		ind = getColorIndex(inr, ing, inb)
-		vwt[ind]++
-		vmr[ind] += r
-		vmg[ind] += g
-		vmb[ind] += b
-		m2[ind] += float64(table[r] + table[g] + table[b])
+		h.wt[ind]++
+		h.mr[ind] += r
+		h.mg[ind] += g
+		h.mb[ind] += b
+		h.m2[ind] += w[0]*float64(r*r) + w[1]*float64(g*g) + w[2]*float64(b*b)
 
-		qadd[i] = ind
+		qadd[i] = int32(ind)
 	}
 	return qadd
 }
@@ -86,27 +137,29 @@ func hist3d(src [][3]int, size int, vwt, vmr, vmg, vmb *[cubeSize]int, m2 *[cube
 */
 
 // m3d Compute cumulative moments. */
-func m3d(vwt, vmr, vmg, vmb *[cubeSize]int, m2 *[cubeSize]float64) {
+func (h *histogram) m3d() {
 	var i, r, g, b int
 	var ind1, ind2 int
 	var line, lineR, lineG, lineB int
 	var line2 float64
 
-	area := [33]int{}
-	areaRed := [33]int{}
-	areaGreen := [33]int{}
-	areaBlue := [33]int{}
-	area2 := [33]float64{}
+	vwt, vmr, vmg, vmb, m2 := h.wt, h.mr, h.mg, h.mb, h.m2
+	last := h.side - 1
+	area := make([]int, h.side)
+	areaRed := make([]int, h.side)
+	areaGreen := make([]int, h.side)
+	areaBlue := make([]int, h.side)
+	area2 := make([]float64, h.side)
 
-	for r = 1; r <= 32; r++ {
-		for i = 0; i <= 32; i++ {
+	for r = 1; r <= last; r++ {
+		for i = 0; i <= last; i++ {
 			area[i], areaRed[i], areaGreen[i], areaBlue[i], area2[i] = 0, 0, 0, 0, 0
 		}
 
-		for g = 1; g <= 32; g++ {
+		for g = 1; g <= last; g++ {
 			line, lineR, lineG, lineB, line2 = 0, 0, 0, 0, 0
-			for b = 1; b <= 32; b++ {
-				ind1 = getColorIndex(r, g, b)
+			for b = 1; b <= last; b++ {
+				ind1 = h.index(r, g, b)
 				line += vwt[ind1]
 				lineR += vmr[ind1]
 				lineG += vmg[ind1]
@@ -119,7 +172,7 @@ func m3d(vwt, vmr, vmg, vmb *[cubeSize]int, m2 *[cubeSize]float64) {
 				areaBlue[b] += lineB
 				area2[b] += line2
 
-				ind2 = ind1 - 1089 /* [r-1][g][b] */
+				ind2 = ind1 - h.side*h.side /* [r-1][g][b] */
 				vwt[ind1] = vwt[ind2] + area[b]
 				vmr[ind1] = vmr[ind2] + areaRed[b]
 				vmg[ind1] = vmg[ind2] + areaGreen[b]
@@ -131,27 +184,27 @@ func m3d(vwt, vmr, vmg, vmb *[cubeSize]int, m2 *[cubeSize]float64) {
 }
 
 // vol Compute sum over a box of any given statistic
-func vol(cube *box, moment *[cubeSize]int) int {
-	return moment[getColorIndex(cube.r1, cube.g1, cube.b1)] -
-		moment[getColorIndex(cube.r1, cube.g1, cube.b0)] -
-		moment[getColorIndex(cube.r1, cube.g0, cube.b1)] +
-		moment[getColorIndex(cube.r1, cube.g0, cube.b0)] -
-		moment[getColorIndex(cube.r0, cube.g1, cube.b1)] +
-		moment[getColorIndex(cube.r0, cube.g1, cube.b0)] +
-		moment[getColorIndex(cube.r0, cube.g0, cube.b1)] -
-		moment[getColorIndex(cube.r0, cube.g0, cube.b0)]
+func (h *histogram) vol(cube *box, moment []int) int {
+	return moment[h.index(cube.r1, cube.g1, cube.b1)] -
+		moment[h.index(cube.r1, cube.g1, cube.b0)] -
+		moment[h.index(cube.r1, cube.g0, cube.b1)] +
+		moment[h.index(cube.r1, cube.g0, cube.b0)] -
+		moment[h.index(cube.r0, cube.g1, cube.b1)] +
+		moment[h.index(cube.r0, cube.g1, cube.b0)] +
+		moment[h.index(cube.r0, cube.g0, cube.b1)] -
+		moment[h.index(cube.r0, cube.g0, cube.b0)]
 }
 
 // volFloat Computes the volume of the cube in a specific moment. For the floating-point values.
-func volFloat(cube *box, moment *[cubeSize]float64) float64 {
-	return moment[getColorIndex(cube.r1, cube.g1, cube.b1)] -
-		moment[getColorIndex(cube.r1, cube.g1, cube.b0)] -
-		moment[getColorIndex(cube.r1, cube.g0, cube.b1)] +
-		moment[getColorIndex(cube.r1, cube.g0, cube.b0)] -
-		moment[getColorIndex(cube.r0, cube.g1, cube.b1)] +
-		moment[getColorIndex(cube.r0, cube.g1, cube.b0)] +
-		moment[getColorIndex(cube.r0, cube.g0, cube.b1)] -
-		moment[getColorIndex(cube.r0, cube.g0, cube.b0)]
+func (h *histogram) volFloat(cube *box, moment []float64) float64 {
+	return moment[h.index(cube.r1, cube.g1, cube.b1)] -
+		moment[h.index(cube.r1, cube.g1, cube.b0)] -
+		moment[h.index(cube.r1, cube.g0, cube.b1)] +
+		moment[h.index(cube.r1, cube.g0, cube.b0)] -
+		moment[h.index(cube.r0, cube.g1, cube.b1)] +
+		moment[h.index(cube.r0, cube.g1, cube.b0)] +
+		moment[h.index(cube.r0, cube.g0, cube.b1)] -
+		moment[h.index(cube.r0, cube.g0, cube.b0)]
 }
 
 /*
@@ -162,63 +215,63 @@ func volFloat(cube *box, moment *[cubeSize]float64) float64 {
 */
 
 // bottom Compute part of Vol(cube, mmt) that doesn't depend on r1, g1, or b1 (depending on dir)
-func bottom(cube *box, direction int, moment *[cubeSize]int) int {
+func (h *histogram) bottom(cube *box, direction int, moment []int) int {
 	switch direction {
 	case red:
-		return -moment[getColorIndex(cube.r0, cube.g1, cube.b1)] +
-			moment[getColorIndex(cube.r0, cube.g1, cube.b0)] +
-			moment[getColorIndex(cube.r0, cube.g0, cube.b1)] -
-			moment[getColorIndex(cube.r0, cube.g0, cube.b0)]
+		return -moment[h.index(cube.r0, cube.g1, cube.b1)] +
+			moment[h.index(cube.r0, cube.g1, cube.b0)] +
+			moment[h.index(cube.r0, cube.g0, cube.b1)] -
+			moment[h.index(cube.r0, cube.g0, cube.b0)]
 	case green:
-		return -moment[getColorIndex(cube.r1, cube.g0, cube.b1)] +
-			moment[getColorIndex(cube.r1, cube.g0, cube.b0)] +
-			moment[getColorIndex(cube.r0, cube.g0, cube.b1)] -
-			moment[getColorIndex(cube.r0, cube.g0, cube.b0)]
+		return -moment[h.index(cube.r1, cube.g0, cube.b1)] +
+			moment[h.index(cube.r1, cube.g0, cube.b0)] +
+			moment[h.index(cube.r0, cube.g0, cube.b1)] -
+			moment[h.index(cube.r0, cube.g0, cube.b0)]
 	case blue:
-		return -moment[getColorIndex(cube.r1, cube.g1, cube.b0)] +
-			moment[getColorIndex(cube.r1, cube.g0, cube.b0)] +
-			moment[getColorIndex(cube.r0, cube.g1, cube.b0)] -
-			moment[getColorIndex(cube.r0, cube.g0, cube.b0)]
+		return -moment[h.index(cube.r1, cube.g1, cube.b0)] +
+			moment[h.index(cube.r1, cube.g0, cube.b0)] +
+			moment[h.index(cube.r0, cube.g1, cube.b0)] -
+			moment[h.index(cube.r0, cube.g0, cube.b0)]
 	default:
 		return 0
 	}
 }
 
 // top Compute remainder of Vol(cube, mmt), substituting pos for r1, g1, or b1 (depending on dir)
-func top(cube *box, direction, position int, moment *[cubeSize]int) int {
+func (h *histogram) top(cube *box, direction, position int, moment []int) int {
 	switch direction {
 	case red:
-		return moment[getColorIndex(position, cube.g1, cube.b1)] -
-			moment[getColorIndex(position, cube.g1, cube.b0)] -
-			moment[getColorIndex(position, cube.g0, cube.b1)] +
-			moment[getColorIndex(position, cube.g0, cube.b0)]
+		return moment[h.index(position, cube.g1, cube.b1)] -
+			moment[h.index(position, cube.g1, cube.b0)] -
+			moment[h.index(position, cube.g0, cube.b1)] +
+			moment[h.index(position, cube.g0, cube.b0)]
 	case green:
-		return moment[getColorIndex(cube.r1, position, cube.b1)] -
-			moment[getColorIndex(cube.r1, position, cube.b0)] -
-			moment[getColorIndex(cube.r0, position, cube.b1)] +
-			moment[getColorIndex(cube.r0, position, cube.b0)]
+		return moment[h.index(cube.r1, position, cube.b1)] -
+			moment[h.index(cube.r1, position, cube.b0)] -
+			moment[h.index(cube.r0, position, cube.b1)] +
+			moment[h.index(cube.r0, position, cube.b0)]
 
 	case blue:
-		return moment[getColorIndex(cube.r1, cube.g1, position)] -
-			moment[getColorIndex(cube.r1, cube.g0, position)] -
-			moment[getColorIndex(cube.r0, cube.g1, position)] +
-			moment[getColorIndex(cube.r0, cube.g0, position)]
+		return moment[h.index(cube.r1, cube.g1, position)] -
+			moment[h.index(cube.r1, cube.g0, position)] -
+			moment[h.index(cube.r0, cube.g1, position)] +
+			moment[h.index(cube.r0, cube.g0, position)]
 	default:
 		return 0
 	}
 }
 
 // variance
-// Compute the weighted variance of a box
+// Compute the weighted variance of a box, the channels are weighted by w
 // NB: as with the raw statistics, this is really the variance * size
-func variance(cube *box, wt, mr, mg, mb *[cubeSize]int, m2 *[cubeSize]float64) float64 {
-	volumeRed := float64(vol(cube, mr))
-	volumeGreen := float64(vol(cube, mg))
-	volumeBlue := float64(vol(cube, mb))
-	volumeMoment := volFloat(cube, m2)
-	volumeWeight := float64(vol(cube, wt))
+func (h *histogram) variance(cube *box, w *[3]float64) float64 {
+	volumeRed := float64(h.vol(cube, h.mr))
+	volumeGreen := float64(h.vol(cube, h.mg))
+	volumeBlue := float64(h.vol(cube, h.mb))
+	volumeMoment := h.volFloat(cube, h.m2)
+	volumeWeight := float64(h.vol(cube, h.wt))
 
-	distance := volumeRed*volumeRed + volumeGreen*volumeGreen + volumeBlue*volumeBlue
+	distance := w[0]*volumeRed*volumeRed + w[1]*volumeGreen*volumeGreen + w[2]*volumeBlue*volumeBlue
 
 	return volumeMoment - (distance / volumeWeight)
 }
@@ -229,35 +282,34 @@ func variance(cube *box, wt, mr, mg, mb *[cubeSize]int, m2 *[cubeSize]float64) f
 // is the same (the sum for the whole box) no matter where we split.
 // The remaining terms have a minus sign in the variance formula,
 // so we drop the minus sign and MAXIMIZE the sum of the two terms.
-func maximize(cube *box, dir, first, last int, cut *int,
-	wholeR, wholeG, wholeB, wholeW int,
-	wt, mr, mg, mb *[cubeSize]int) float64 {
+func (h *histogram) maximize(cube *box, dir, first, last int, cut *int,
+	wholeR, wholeG, wholeB, wholeW int, w *[3]float64) float64 {
 
 	var i int
 	var halfR, halfG, halfB, halfW int
 	var baseR, baseG, baseB, baseW int
 	var temp, max float64
 
-	baseR = bottom(cube, dir, mr)
-	baseG = bottom(cube, dir, mg)
-	baseB = bottom(cube, dir, mb)
-	baseW = bottom(cube, dir, wt)
+	baseR = h.bottom(cube, dir, h.mr)
+	baseG = h.bottom(cube, dir, h.mg)
+	baseB = h.bottom(cube, dir, h.mb)
+	baseW = h.bottom(cube, dir, h.wt)
 
 	max = 0.0
 	*cut = -1
 
 	for i = first; i < last; i++ {
 		// determines the cube cut at a certain position
-		halfR = baseR + top(cube, dir, i, mr)
-		halfG = baseG + top(cube, dir, i, mg)
-		halfB = baseB + top(cube, dir, i, mb)
-		halfW = baseW + top(cube, dir, i, wt)
+		halfR = baseR + h.top(cube, dir, i, h.mr)
+		halfG = baseG + h.top(cube, dir, i, h.mg)
+		halfB = baseB + h.top(cube, dir, i, h.mb)
+		halfW = baseW + h.top(cube, dir, i, h.wt)
 
 		/* now half_x is sum over lower half of box, if split at i */
 		if halfW == 0 {
 			continue // sub box could be empty of pixels!, never split into an empty box
 		} else {
-			temp = float64(halfR*halfR+halfG*halfG+halfB*halfB) / float64(halfW)
+			temp = sqNorm(halfR, halfG, halfB, w) / float64(halfW)
 		}
 
 		halfR = wholeR - halfR
@@ -267,7 +319,7 @@ func maximize(cube *box, dir, first, last int, cut *int,
 		if halfW == 0 {
 			continue // sub box could be empty of pixels! Never split into an empty box
 		} else {
-			temp += float64(halfR*halfR+halfG*halfG+halfB*halfB) / float64(halfW)
+			temp += sqNorm(halfR, halfG, halfB, w) / float64(halfW)
 		}
 
 		if temp > max {
@@ -279,20 +331,25 @@ func maximize(cube *box, dir, first, last int, cut *int,
 	return max
 }
 
-func cut(set1, set2 *box, wt, mr, mg, mb *[cubeSize]int) bool {
+// sqNorm return the weighted r^2 + g^2 + b^2 without overflowing on the sums of 16-bit channels
+func sqNorm(r, g, b int, w *[3]float64) float64 {
+	return w[0]*float64(r)*float64(r) + w[1]*float64(g)*float64(g) + w[2]*float64(b)*float64(b)
+}
+
+func (h *histogram) cut(set1, set2 *box, w *[3]float64) bool {
 	var dir int
 	var cutR, cutG, cutB int
 	var wholeR, wholeG, wholeB, wholeW int
 	var maxR, maxG, maxB float64
 
-	wholeR = vol(set1, mr)
-	wholeG = vol(set1, mg)
-	wholeB = vol(set1, mb)
-	wholeW = vol(set1, wt)
+	wholeR = h.vol(set1, h.mr)
+	wholeG = h.vol(set1, h.mg)
+	wholeB = h.vol(set1, h.mb)
+	wholeW = h.vol(set1, h.wt)
 
-	maxR = maximize(set1, red, set1.r0+1, set1.r1, &cutR, wholeR, wholeG, wholeB, wholeW, wt, mr, mg, mb)
-	maxG = maximize(set1, green, set1.g0+1, set1.g1, &cutG, wholeR, wholeG, wholeB, wholeW, wt, mr, mg, mb)
-	maxB = maximize(set1, blue, set1.b0+1, set1.b1, &cutB, wholeR, wholeG, wholeB, wholeW, wt, mr, mg, mb)
+	maxR = h.maximize(set1, red, set1.r0+1, set1.r1, &cutR, wholeR, wholeG, wholeB, wholeW, w)
+	maxG = h.maximize(set1, green, set1.g0+1, set1.g1, &cutG, wholeR, wholeG, wholeB, wholeW, w)
+	maxB = h.maximize(set1, blue, set1.b0+1, set1.b1, &cutB, wholeR, wholeG, wholeB, wholeW, w)
 
 	if (maxR >= maxG) && (maxR >= maxB) {
 		dir = red
@@ -329,56 +386,264 @@ func cut(set1, set2 *box, wt, mr, mg, mb *[cubeSize]int) bool {
 	return true
 }
 
-func mark(cube *box, label int, tag *[cubeSize]int) {
+func (h *histogram) mark(cube *box, label int32, tag []int32) {
 	var r, g, b int
 	for r = cube.r0 + 1; r <= cube.r1; r++ {
 		for g = cube.g0 + 1; g <= cube.g1; g++ {
 			for b = cube.b0 + 1; b <= cube.b1; b++ {
-				tag[getColorIndex(r, g, b)] = label
+				tag[h.index(r, g, b)] = label
 			}
 		}
 	}
 }
 
+// Result is the palette of a quantization along with the statistics of each color
+type Result struct {
+	Palette   [][3]int  // colors sorted by decreasing pixel count
+	Counts    []int     // number of pixels mapped to each color
+	Variances []float64 // mean weighted squared distance of these pixels to their color, in the color space
+	lut       []int32   // palette index of every histogram cell, -1 for cells of empty boxes
+	axes      *axes     // placement of the colors in the histogram
+}
+
+// Index return the palette index of the box holding the color,
+// colors falling in a box that held no pixel are mapped to the nearest color of the palette
+func (r *Result) Index(c [3]int) int {
+	ind, _ := r.axes.locate(c)
+	i := int(r.lut[ind])
+	if i >= 0 {
+		return i
+	}
+
+	var dist, minDist int
+	minDist = -1
+	for j, p := range r.Palette {
+		dist = (c[0]-p[0])*(c[0]-p[0]) + (c[1]-p[1])*(c[1]-p[1]) + (c[2]-p[2])*(c[2]-p[2])
+		if minDist < 0 || dist < minDist {
+			i, minDist = j, dist
+		}
+	}
+	return i
+}
+
+// QuantWu return a palette of k colors, padded with black when the pixels fill fewer cells of the histogram
 func QuantWu(pixels [][3]int, k int) [][3]int {
-	var lutRgb [maxColor][3]int
-	var qadd []int
-	var tag [cubeSize]int
+	palettes := make([][3]int, k)
+	if result := Quantize(pixels, k); result != nil {
+		copy(palettes, result.Palette)
+	}
+	return palettes
+}
+
+// Options describes the pixels to quantize, zero fields fall back to the defaults
+type Options struct {
+	Depth    int              // bits per channel of the pixels, 8 by default up to 16
+	Space    colorspace.Space // space in which the boxes are split and averaged, RGB by default
+	Weights  [3]float64       // weight of the squared error along each channel or axis, all zero for unit weights
+	HistBits int              // histogram precision per channel, 5 by default up to 7
+}
+
+var (
+	// Rec601 weighs the RGB channels by their share of the BT.601 luma
+	Rec601 = [3]float64{0.299, 0.587, 0.114}
+	// Rec709 weighs the RGB channels by their share of the BT.709 luma
+	Rec709 = [3]float64{0.2126, 0.7152, 0.0722}
+)
+
+// scale is the range of the moments summed in linear light or in another color space,
+// wide enough to keep the dark shades apart
+const scale = 1<<16 - 1
+
+// axes map the pixels to the cells of the histogram and to the values summed in its moments.
+// RGB pixels are binned and summed as they are, LinearRGB pixels are binned as they are but summed
+// in linear light, and the other spaces are binned over the range of the sRGB gamut along each axis.
+type axes struct {
+	space  colorspace.Space
+	depth  int
+	shift  int        // bits dropped from the channels to index the histogram
+	cells  int        // cells along each axis of the histogram
+	linear []int      // linear light of every channel value
+	min    [3]float64 // lowest coordinates of the gamut
+	cell   [3]float64 // extent of a cell along each axis
+}
+
+func newAxes(space colorspace.Space, depth, bits int) (*axes, error) {
+	a := &axes{space: space, depth: depth, shift: depth - bits, cells: 1 << bits}
+	switch space {
+	case colorspace.RGB:
+	case colorspace.LinearRGB:
+		max := float64(int(1)<<depth - 1)
+		a.linear = make([]int, 1<<depth)
+		for i := range a.linear {
+			a.linear[i] = int(math.Round(colorspace.ToLinear(float64(i)/max) * scale))
+		}
+	case colorspace.Lab, colorspace.OKLab, colorspace.YCbCr:
+		min, max := space.Bounds()
+		a.min = min
+		for i := range a.cell {
+			a.cell[i] = (max[i] - min[i]) / float64(a.cells)
+		}
+	default:
+		return nil, fmt.Errorf("wu: unsupported color space %v", space)
+	}
+	return a, nil
+}
+
+// locate return the histogram index of the color and the values it adds to the moments
+func (a *axes) locate(c [3]int) (int, [3]int) {
+	if a.cell[0] != 0 {
+		return a.project(c)
+	}
+	ind := getColorIndex(a.cells+1, (c[0]>>a.shift)+1, (c[1]>>a.shift)+1, (c[2]>>a.shift)+1)
+	if a.linear != nil {
+		c = [3]int{a.linear[c[0]], a.linear[c[1]], a.linear[c[2]]}
+	}
+	return ind, c
+}
+
+// project locate the color in a space other than RGB and LinearRGB
+func (a *axes) project(c [3]int) (int, [3]int) {
+	var cell [3]int
+
+	max := float64(int(1)<<a.depth - 1)
+	p := a.space.FromRGB([3]float64{float64(c[0]) / max, float64(c[1]) / max, float64(c[2]) / max})
+	for i := range p {
+		p[i] -= a.min[i]
+		cell[i] = int(p[i] / a.cell[i])
+		if cell[i] < 0 {
+			cell[i] = 0
+		} else if cell[i] >= a.cells {
+			cell[i] = a.cells - 1
+		}
+		c[i] = int(math.Round(p[i] * scale))
+	}
+	return getColorIndex(a.cells+1, cell[0]+1, cell[1]+1, cell[2]+1), c
+}
+
+// color return the sRGB color of the mean values of a box
+func (a *axes) color(v [3]int) [3]int {
+	var p [3]float64
+
+	max := float64(int(1)<<a.depth - 1)
+	switch a.space {
+	case colorspace.RGB:
+		return v
+	case colorspace.LinearRGB:
+		for i := range p {
+			p[i] = colorspace.FromLinear(math.Min(float64(v[i])/scale, 1))
+		}
+	default:
+		p = a.space.ToRGB([3]float64{float64(v[0])/scale + a.min[0], float64(v[1])/scale + a.min[1], float64(v[2])/scale + a.min[2]})
+	}
+	for i := range p {
+		v[i] = int(math.Round(math.Max(0, math.Min(p[i], 1)) * max))
+	}
+	return v
+}
+
+// unit return the factor bringing the variances of the moments to the scale of the channels
+func (a *axes) unit() float64 {
+	if a.space == colorspace.RGB {
+		return 1
+	}
+	return math.Pow(float64(int(1)<<a.depth-1)/scale, 2)
+}
+
+// Quantize return at most k colors along with the pixel count and variance of their boxes,
+// nil when k is not positive
+func Quantize(pixels [][3]int, k int) *Result {
+	result, _ := QuantizeOptions(pixels, k, Options{})
+	return result
+}
+
+// QuantizeOptions is Quantize for pixels described by the options. Every box holds at least one
+// non-empty cell of the histogram, so whatever k the palette is capped to the number of these cells,
+// a finer histogram holds more colors.
+func QuantizeOptions(pixels [][3]int, k int, opts Options) (*Result, error) {
+	var lutRgb [][3]int
+	var qadd []int32
+	var tag []int32
 	var next int
 	var i, j int
 	var weight int
 	var size int
+	var cells int
 	var maxColors int
-	var wt, mr, mg, mb [cubeSize]int
-	var m2 [cubeSize]float64
+	var h *histogram
 	var temp float64
-	var vv [maxColor]float64
-	var cube [maxColor]box
+	var vv []float64
+	var cube []box
 	var count []float64
 	var rank []int
-	var palettes [][3]int
+	var order []int32
+	var result *Result
+	var a *axes
+	var err error
 
-	maxColors = k
+	if opts.Depth == 0 {
+		opts.Depth = 8
+	}
+	if opts.HistBits == 0 {
+		opts.HistBits = HistBits
+	}
+	if opts.HistBits < MinHistBits || opts.HistBits > MaxHistBits {
+		return nil, fmt.Errorf("wu: histogram bits should be between %d and %d, got %d", MinHistBits, MaxHistBits, opts.HistBits)
+	}
+	if opts.Depth < opts.HistBits || opts.Depth > 16 {
+		return nil, fmt.Errorf("wu: channel depth should be between the histogram bits and 16, got %d", opts.Depth)
+	}
+	if opts.Weights == [3]float64{} {
+		opts.Weights = [3]float64{1, 1, 1}
+	}
+	if opts.Weights[0] < 0 || opts.Weights[1] < 0 || opts.Weights[2] < 0 {
+		return nil, fmt.Errorf("wu: channel weights should not be negative, got %v", opts.Weights)
+	}
+	if a, err = newAxes(opts.Space, opts.Depth, opts.HistBits); err != nil {
+		return nil, err
+	}
+
+	if k < 1 {
+		return nil, fmt.Errorf("wu: number of colors should be greater than 0, got %d", k)
+	}
 
 	size = len(pixels)
-	qadd = hist3d(pixels, size, &wt, &mr, &mg, &mb, &m2)
+	h = newHistogram(opts.HistBits)
+	defer h.release()
+	qadd = h.hist3d(pixels, size, a, &opts.Weights)
+
+	// every box holds at least one non-empty cell, but the whole cube of an empty histogram
+	for i = range h.wt {
+		if h.wt[i] != 0 {
+			cells++
+		}
+	}
+	if cells == 0 {
+		cells = 1
+	}
+	maxColors = k
+	if maxColors > cells {
+		maxColors = cells
+	}
+	lutRgb = make([][3]int, maxColors)
+	vv = make([]float64, maxColors)
+	cube = make([]box, maxColors)
 
-	m3d(&wt, &mr, &mg, &mb, &m2)
+	h.m3d()
 
-	cube[0] = box{r1: 32, g1: 32, b1: 32}
+	cube[0] = box{r1: h.side - 1, g1: h.side - 1, b1: h.side - 1}
 
 	next = 0
 	for i = 1; i < maxColors; i++ {
-		if cut(&cube[next], &cube[i], &wt, &mr, &mg, &mb) {
+		if h.cut(&cube[next], &cube[i], &opts.Weights) {
 			/* Volume test ensures we won't try to cut one-cell box */
 			if cube[next].vol > 1 {
-				vv[next] = variance(&cube[next], &wt, &mr, &mg, &mb, &m2)
+				vv[next] = h.variance(&cube[next], &opts.Weights)
 			} else {
 				vv[next] = 0
 			}
 
 			if cube[i].vol > 1 {
-				vv[i] = variance(&cube[i], &wt, &mr, &mg, &mb, &m2)
+				vv[i] = h.variance(&cube[i], &opts.Weights)
 			} else {
 				vv[i] = 0
 			}
@@ -402,14 +667,19 @@ func QuantWu(pixels [][3]int, k int) [][3]int {
 		}
 	}
 
+	// the box of every cell, turned into the palette index once the boxes are ranked
+	tag = make([]int32, len(h.wt))
 	for i = 0; i < maxColors; i++ {
-		mark(&cube[i], i, &tag)
-		weight = vol(&cube[i], &wt)
+		h.mark(&cube[i], int32(i), tag)
+		weight = h.vol(&cube[i], h.wt)
 
 		if weight > 0 {
-			lutRgb[i][0], lutRgb[i][1], lutRgb[i][2] = vol(&cube[i], &mr)/weight, vol(&cube[i], &mg)/weight, vol(&cube[i], &mb)/weight
+			lutRgb[i][0], lutRgb[i][1], lutRgb[i][2] = h.vol(&cube[i], h.mr)/weight, h.vol(&cube[i], h.mg)/weight, h.vol(&cube[i], h.mb)/weight
+			lutRgb[i] = a.color(lutRgb[i])
+			vv[i] = h.variance(&cube[i], &opts.Weights) / float64(weight) * a.unit()
 		} else { /* Bogux box */
 			lutRgb[i][0], lutRgb[i][1], lutRgb[i][2] = 0, 0, 0
+			vv[i] = 0
 		}
 	}
 
@@ -419,9 +689,27 @@ func QuantWu(pixels [][3]int, k int) [][3]int {
 	}
 
 	rank = argsort.Quicksort(count)
-	palettes = make([][3]int, k)
+	result = &Result{
+		Palette:   make([][3]int, 0, maxColors),
+		Counts:    make([]int, 0, maxColors),
+		Variances: make([]float64, 0, maxColors),
+		lut:       tag,
+		axes:      a,
+	}
+	order = make([]int32, maxColors)
 	for i = 0; i < maxColors; i++ {
-		palettes[i] = lutRgb[rank[maxColors-1-i]]
+		j = rank[maxColors-1-i]
+		if count[j] == 0 {
+			order[j] = -1 // bogus boxes only hold empty cells, they rank last
+			continue
+		}
+		order[j] = int32(len(result.Palette))
+		result.Palette = append(result.Palette, lutRgb[j])
+		result.Counts = append(result.Counts, int(count[j]))
+		result.Variances = append(result.Variances, vv[j])
 	}
-	return palettes
+	for i = range tag {
+		tag[i] = order[tag[i]]
+	}
+	return result, nil
 }
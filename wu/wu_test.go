@@ -1,16 +1,23 @@
 package wu
 
 import (
+	"color-thief/colorspace"
 	"color-thief/helper"
+	"image"
 	"log"
 	"reflect"
+	"strconv"
 	"testing"
 )
 
-var p [][3]int
+var (
+	img image.Image
+	p   [][3]int
+)
 
 func init() {
-	img, err := helper.ReadImage("../example/photo1.jpg")
+	var err error
+	img, err = helper.ReadImage("../example/photo1.jpg")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -39,3 +46,196 @@ func BenchmarkQuantWu(b *testing.B) {
 		_ = QuantWu(p, 6)
 	}
 }
+
+// BenchmarkQuantWuFromImage includes the sampling of the decoded JPEG
+func BenchmarkQuantWuFromImage(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = QuantWu(helper.SubsamplingPixelsFromImage(img), 6)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	result := Quantize(p, 6)
+	for i, c := range result.Palette {
+		if idx := result.Index(c); idx != i {
+			t.Errorf("color %v should map to itself (%d), got %d", c, i, idx)
+		}
+	}
+
+	// every sampled pixel falls in a box of the palette, the counts follow
+	counts := make([]int, len(result.Palette))
+	for _, c := range p {
+		counts[result.Index(c)]++
+	}
+	if !reflect.DeepEqual(counts, result.Counts) {
+		t.Errorf("expected counts %v, got %v", result.Counts, counts)
+	}
+}
+
+func TestLinear(t *testing.T) {
+	// a red and green checker mixes to yellow in linear light, to a murky olive in sRGB
+	checker := make([][3]int, 0, 64)
+	for i := 0; i < 32; i++ {
+		checker = append(checker, [3]int{255, 0, 0}, [3]int{0, 255, 0})
+	}
+	result, err := QuantizeOptions(checker, 1, Options{})
+	if err != nil || result.Palette[0] != [3]int{127, 127, 0} {
+		t.Errorf("expected the sRGB mean, got %v, %v", result, err)
+	}
+	if result, err = QuantizeOptions(checker, 1, Options{Space: colorspace.LinearRGB}); err != nil || result.Palette[0] != [3]int{188, 188, 0} {
+		t.Errorf("expected the linear light mean, got %v, %v", result, err)
+	}
+
+	// the linear light boxes still split on the same colors
+	result, err = QuantizeOptions(p, 6, Options{Space: colorspace.LinearRGB})
+	if err != nil || len(result.Palette) != 6 {
+		t.Fatalf("unexpected result %v, %v", result, err)
+	}
+	for i, c := range result.Palette {
+		if idx := result.Index(c); idx != i {
+			t.Errorf("color %v should map to itself (%d), got %d", c, i, idx)
+		}
+	}
+}
+
+func TestColorSpaces(t *testing.T) {
+	// the boxes split along the axes of the space still tag every color
+	for _, k := range []int{6, 16} {
+		for _, space := range []colorspace.Space{colorspace.Lab, colorspace.OKLab, colorspace.YCbCr} {
+			result, err := QuantizeOptions(p, k, Options{Space: space})
+			if err != nil || len(result.Palette) != k {
+				t.Fatalf("%v: unexpected result %v, %v", space, result, err)
+			}
+			counts := make([]int, k)
+			for _, c := range p {
+				counts[result.Index(c)]++
+			}
+			if !reflect.DeepEqual(counts, result.Counts) {
+				t.Errorf("%v: expected counts %v, got %v", space, result.Counts, counts)
+			}
+		}
+	}
+
+	if _, err := QuantizeOptions(p, 6, Options{Space: colorspace.Space(-1)}); err == nil {
+		t.Error("expected an error for an unknown color space")
+	}
+}
+
+func TestWeights(t *testing.T) {
+	explicit, err := QuantizeOptions(p, 6, Options{Weights: [3]float64{1, 1, 1}})
+	if err != nil || !reflect.DeepEqual(explicit.Palette, QuantWu(p, 6)) {
+		t.Errorf("unit weights should match QuantWu, got %v, %v", explicit, err)
+	}
+	// only the ratios of the weights matter to the splits
+	if scaled, _ := QuantizeOptions(p, 6, Options{Weights: [3]float64{4, 4, 4}}); !reflect.DeepEqual(scaled.Palette, explicit.Palette) {
+		t.Errorf("uniformly scaled weights should not change the palette, got %v", scaled.Palette)
+	}
+	if luma, err := QuantizeOptions(p, 6, Options{Weights: Rec709}); err != nil || len(luma.Palette) != 6 {
+		t.Errorf("unexpected result with luma weights %v, %v", luma, err)
+	}
+
+	// blue spreads further than green, unless its errors hardly count
+	pixels := make([][3]int, 0, 400)
+	for i := 0; i < 100; i++ {
+		pixels = append(pixels, [3]int{128, 100, 0}, [3]int{128, 140, 0}, [3]int{128, 100, 255}, [3]int{128, 140, 255})
+	}
+	result, _ := QuantizeOptions(pixels, 2, Options{})
+	if result.Palette[0][1] != 120 || result.Palette[1][1] != 120 {
+		t.Errorf("expected a split along blue, got %v", result.Palette)
+	}
+	result, _ = QuantizeOptions(pixels, 2, Options{Weights: [3]float64{1, 1, 0.01}})
+	if result.Palette[0][2] != 127 || result.Palette[1][2] != 127 {
+		t.Errorf("expected a split along green, got %v", result.Palette)
+	}
+
+	if _, err = QuantizeOptions(p, 6, Options{Weights: [3]float64{1, -1, 1}}); err == nil {
+		t.Error("expected an error for a negative weight")
+	}
+}
+
+func TestHistBits(t *testing.T) {
+	// a subtle gradient fits in a single cell of the default histogram
+	gradient := make([][3]int, 0, 64)
+	for i := 0; i < 64; i++ {
+		gradient = append(gradient, [3]int{200 + i%8, 150, 120})
+	}
+	if result, _ := QuantizeOptions(gradient, 2, Options{}); len(result.Palette) != 1 {
+		t.Errorf("expected the gradient to collapse into one color, got %v", result.Palette)
+	}
+	if result, _ := QuantizeOptions(gradient, 2, Options{HistBits: 7}); !reflect.DeepEqual(result.Palette, [][3]int{{201, 150, 120}, {205, 150, 120}}) &&
+		!reflect.DeepEqual(result.Palette, [][3]int{{205, 150, 120}, {201, 150, 120}}) {
+		t.Errorf("expected the gradient to split at 7 bits, got %v", result.Palette)
+	}
+
+	for bits := MinHistBits; bits <= MaxHistBits; bits++ {
+		result, err := QuantizeOptions(p, 16, Options{HistBits: bits})
+		if err != nil || len(result.Palette) != 16 {
+			t.Fatalf("%d bits: unexpected result %v, %v", bits, result, err)
+		}
+		counts := make([]int, len(result.Palette))
+		for _, c := range p {
+			counts[result.Index(c)]++
+		}
+		if !reflect.DeepEqual(counts, result.Counts) {
+			t.Errorf("%d bits: expected counts %v, got %v", bits, result.Counts, counts)
+		}
+		// the recycled moment tables start out empty
+		if again, _ := QuantizeOptions(p, 16, Options{HistBits: bits}); !reflect.DeepEqual(again, result) {
+			t.Errorf("%d bits: a second quantization differs", bits)
+		}
+	}
+
+	for _, bits := range []int{4, 8} {
+		if _, err := QuantizeOptions(p, 6, Options{HistBits: bits}); err == nil {
+			t.Errorf("expected an error for %d histogram bits", bits)
+		}
+	}
+}
+
+func BenchmarkHistBits(b *testing.B) {
+	for bits := MinHistBits; bits <= MaxHistBits; bits++ {
+		b.Run(strconv.Itoa(bits), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, _ = QuantizeOptions(p, 16, Options{HistBits: bits})
+			}
+		})
+	}
+}
+
+func TestManyColors(t *testing.T) {
+	for _, k := range []int{512, 4096} {
+		result, err := QuantizeOptions(p, k, Options{})
+		if err != nil || len(result.Palette) != k {
+			t.Fatalf("%d colors: unexpected result %d, %v", k, len(result.Palette), err)
+		}
+		counts := make([]int, k)
+		for _, c := range p {
+			counts[result.Index(c)]++
+		}
+		if !reflect.DeepEqual(counts, result.Counts) {
+			t.Errorf("%d colors: the counts do not follow the boxes", k)
+		}
+	}
+	if palette := QuantWu(p, 300); len(palette) != 300 || palette[299] == [3]int{} {
+		t.Errorf("expected 300 colors, got %d", len(palette))
+	}
+
+	// pixels filling fewer cells give a shorter palette whatever k
+	few := [][3]int{{255, 0, 0}, {0, 255, 0}, {0, 0, 255}}
+	for _, k := range []int{4, 256, 257, 1 << 20} {
+		if result, err := QuantizeOptions(few, k, Options{}); err != nil || len(result.Palette) != 3 {
+			t.Errorf("%d colors: expected 3 colors, got %v, %v", k, result, err)
+		}
+	}
+	if palette := QuantWu(few, 300); len(palette) != 300 || palette[2] == [3]int{} || palette[3] != [3]int{} {
+		t.Errorf("expected 3 colors padded with black, got %v", palette[:4])
+	}
+	if _, err := QuantizeOptions(p, 0, Options{}); err == nil {
+		t.Error("expected an error for 0 colors")
+	}
+	if result, err := QuantizeOptions(nil, 6, Options{}); err != nil || len(result.Palette) != 0 {
+		t.Errorf("expected an empty palette without pixels, got %v, %v", result, err)
+	}
+}